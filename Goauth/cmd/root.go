@@ -8,6 +8,7 @@ import (
 	"syscall"
 
 	crypto "github.com/Aran404/Goauth/internal/crypto"
+	mongo "github.com/Aran404/Goauth/internal/database/mongo"
 	redis "github.com/Aran404/Goauth/internal/database/redis"
 	log "github.com/Aran404/Goauth/internal/logger"
 	server "github.com/Aran404/Goauth/internal/server"
@@ -61,6 +62,12 @@ var (
 			envMap["API_KEY"] = apiKey
 			envMap["JWT_SECRET"] = jwtToken
 
+			// Rotating the pepper would orphan every stored license, so it is only generated once
+			if envMap["LICENSE_PEPPER"] == "" {
+				envMap["LICENSE_PEPPER"] = crypto.GenerateJWTKey(jwtSizeInt)
+				cmd.Println("License Pepper: " + envMap["LICENSE_PEPPER"])
+			}
+
 			if err := godotenv.Write(envMap, ".env"); err != nil {
 				log.Fatal(log.GetStackTrace(), "Could not write .env file: %v", err.Error())
 			}
@@ -75,6 +82,31 @@ var (
 			Start()
 		},
 	}
+
+	migrateCmd = &cobra.Command{
+		Use:   "migrate",
+		Short: "Hashes plain text license keys and moves integrity pins",
+		Long:  `Hashes every license key that is still stored in plain text using the LICENSE_PEPPER, then moves legacy integrity pins into the build registry.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			ctx := context.Background()
+			db := mongo.NewConn(ctx)
+			defer db.Close(ctx)
+
+			count, err := server.HashLicenseKeys(ctx, db, licensePepper())
+			if err != nil {
+				log.Fatal(log.GetStackTrace(), "Could not hash license keys after %v licenses: %v", count, err.Error())
+			}
+
+			cmd.Printf("Hashed %v license keys\n", count)
+
+			count, err = server.MoveIntegrityPins(ctx, db)
+			if err != nil {
+				log.Fatal(log.GetStackTrace(), "Could not move integrity pins after %v applications: %v", count, err.Error())
+			}
+
+			cmd.Printf("Moved %v integrity pins\n", count)
+		},
+	}
 )
 
 func Execute() {
@@ -85,7 +117,7 @@ func Execute() {
 
 func init() {
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
-	rootCmd.AddCommand(genCmd, startCmd)
+	rootCmd.AddCommand(genCmd, startCmd, migrateCmd)
 	genCmd.PersistentFlags().IntP("api-key-size", "a", 32, "Size of the API key")
 	genCmd.PersistentFlags().IntP("jwt-token-size", "j", 32, "Size of the JWT key")
 
@@ -93,6 +125,15 @@ func init() {
 	signal.Notify(Signal, syscall.SIGINT, syscall.SIGTERM)
 }
 
+func licensePepper() []byte {
+	pepper := os.Getenv("LICENSE_PEPPER")
+	if pepper == "" {
+		log.Fatal(log.GetStackTrace(), "No LICENSE_PEPPER found, run the gen command first")
+	}
+
+	return []byte(pepper)
+}
+
 func Start() {
 	redisPort := os.Getenv("REDIS_PORT")
 	if redisPort == "" {
@@ -112,7 +153,7 @@ func Start() {
 	ctx := context.Background()
 	rdb := redis.NewClient(ctx, "localhost:"+redisPort)
 	jwtSecret := []byte(os.Getenv("JWT_SECRET"))
-	s := server.NewServer(ctx, rdb, jwtSecret)
+	s := server.NewServer(ctx, rdb, jwtSecret, licensePepper())
 
 	go func() {
 		if err := fasthttp.ListenAndServe(":"+wsPort, fastws.Upgrade(s.ServeHello)); err != nil {
@@ -120,6 +161,9 @@ func Start() {
 		}
 	}()
 
+	go s.RunWebhookWorker()
+	go s.RunArchivePurger()
+
 	go func() {
 		s.DefaultOptions()
 		s.Bind()
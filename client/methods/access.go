@@ -0,0 +1,91 @@
+package sdk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// SetAccessRule denies or allows a fingerprint or an IP/CIDR for an application and returns the stored value.
+// An expiry of 0 never expires, setting the same kind and value again replaces the rule.
+func (c *Client) SetAccessRule(appID, kind, value, action, reason string, expiresAt int64) (string, error) {
+	payload, err := json.Marshal(map[string]any{
+		"owner_id":   c.OwnerID,
+		"app_id":     appID,
+		"kind":       kind,
+		"value":      value,
+		"action":     action,
+		"reason":     reason,
+		"expires_at": expiresAt,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	resp := c.Request("POST", "/set-access-rule", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return "", resp.Error
+	}
+
+	if !resp.Ok {
+		return "", fmt.Errorf("could not set access rule, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return "", err
+	}
+
+	stored, ok := resp.JSON["value"].(string)
+	if !ok {
+		return "", errors.New("improper response from server")
+	}
+
+	return stored, nil
+}
+
+func (c *Client) ListAccessRules(appID string) ([]AccessRule, error) {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID, "app_id": appID})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := c.Request("POST", "/list-access-rules", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	if !resp.Ok {
+		return nil, fmt.Errorf("could not list access rules, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return nil, err
+	}
+
+	var rules []AccessRule
+	if err := mapstructure.Decode(resp.JSON["rules"], &rules); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func (c *Client) DeleteAccessRule(appID, ruleID string) error {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID, "app_id": appID, "rule_id": ruleID})
+	if err != nil {
+		return err
+	}
+
+	resp := c.Request("POST", "/delete-access-rule", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if !resp.Ok {
+		return fmt.Errorf("could not delete access rule, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	return ParseEncryptedResponse(resp.JSON)
+}
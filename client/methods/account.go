@@ -47,7 +47,40 @@ func (c *Client) Login(username, password string) (*LoginInfo, error) {
 		return nil, err
 	}
 
-	resp := c.Request("POST", "/login", payload, true)
+	resp := c.Request("POST", "/login", payload, true, c.deviceHeaders())
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	if !resp.Ok {
+		return nil, fmt.Errorf("could not login, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return nil, err
+	}
+
+	var data LoginInfo
+	if err := mapstructure.Decode(resp.JSON, &data); err != nil {
+		return nil, err
+	}
+
+	if data.TwoFactor {
+		return &data, nil
+	}
+
+	c.auth = &data
+	return c.auth, nil
+}
+
+// LoginTwoFactor finishes a login that returned a ticket, code is a TOTP code or a recovery code.
+func (c *Client) LoginTwoFactor(ticket, code string) (*LoginInfo, error) {
+	payload, err := json.Marshal(map[string]any{"ticket": ticket, "code": code})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := c.Request("POST", "/login-2fa", payload, true, c.deviceHeaders())
 	if resp.Error != nil {
 		return nil, resp.Error
 	}
@@ -82,7 +115,88 @@ func (c *Client) Logout() error {
 	return ParseEncryptedResponse(resp.JSON)
 }
 
+// ChangePassword signs out every other session, the client keeps working with the new tokens.
+func (c *Client) ChangePassword(password, newPassword string) error {
+	payload, err := json.Marshal(map[string]any{"password": password, "new_password": newPassword})
+	if err != nil {
+		return err
+	}
+
+	return c.renewSession("/change-password", "change password", payload)
+}
+
+// ChangeUsername renames the account, the client keeps working with the new tokens.
+func (c *Client) ChangeUsername(username string) error {
+	payload, err := json.Marshal(map[string]any{"username": username})
+	if err != nil {
+		return err
+	}
+
+	return c.renewSession("/change-username", "change username", payload)
+}
+
+// renewSession calls an account route that answers with a new key pair and switches the client over to it.
+func (c *Client) renewSession(path, action string, payload []byte) error {
+	resp := c.Request("POST", path, payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if !resp.Ok {
+		return fmt.Errorf("could not %v, status code: %v, body: %v", action, resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return err
+	}
+
+	var data LoginInfo
+	if err := mapstructure.Decode(resp.JSON, &data); err != nil {
+		return err
+	}
+
+	c.auth = &data
+	return nil
+}
+
+// DeleteAccount fails while the account is the primary user of an owner that still has applications.
+func (c *Client) DeleteAccount(password string) error {
+	payload, err := json.Marshal(map[string]any{"password": password})
+	if err != nil {
+		return err
+	}
+
+	resp := c.Request("POST", "/delete-account", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if !resp.Ok {
+		return fmt.Errorf("could not delete account, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return err
+	}
+
+	c.auth = nil
+	return nil
+}
+
+// deviceHeaders names the session a login starts.
+func (c *Client) deviceHeaders() http.Header {
+	if c.Device == "" {
+		return http.Header{}
+	}
+
+	return http.Header{"X-Device-Name": []string{c.Device}}
+}
+
 func (c *Client) authHeaders() http.Header {
+	if c.APIToken != "" {
+		return http.Header{"X-Api-Token": []string{c.APIToken}}
+	}
+
 	return http.Header{
 		"Authorization": []string{fmt.Sprintf("Bearer %s", c.auth.Token)},
 		"Cookie":        []string{fmt.Sprint("refresh_token=", c.auth.RefreshToken)},
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+
+	"github.com/mitchellh/mapstructure"
 )
 
 func (c *Client) CreateOwner(userID string) (string, error) {
@@ -32,3 +34,158 @@ func (c *Client) CreateOwner(userID string) (string, error) {
 
 	return id, nil
 }
+
+// DeleteOwner archives an owner with all of its applications, the returned archive ID is empty when permanent.
+func (c *Client) DeleteOwner(ownerID string, permanent bool) (string, error) {
+	payload, err := json.Marshal(map[string]any{"owner_id": ownerID, "permanent": permanent})
+	if err != nil {
+		return "", err
+	}
+
+	return c.deleteRequest("/delete-owner", "owner", payload)
+}
+
+// SetUserRole changes a user's role, permissions are granted on top of what the role allows.
+func (c *Client) SetUserRole(userID, role string, permissions ...string) error {
+	payload, err := json.Marshal(map[string]any{"user_id": userID, "role": role, "permissions": permissions})
+	if err != nil {
+		return err
+	}
+
+	resp := c.Request("POST", "/set-user-role", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if !resp.Ok {
+		return fmt.Errorf("could not set user role, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	return ParseEncryptedResponse(resp.JSON)
+}
+
+// SetUserStatus suspends or reinstates an account, expiresAt is a unix timestamp and 0 never expires.
+func (c *Client) SetUserStatus(userID string, suspended bool, reason string, expiresAt int64) error {
+	payload, err := json.Marshal(map[string]any{"user_id": userID, "suspended": suspended, "reason": reason, "expires_at": expiresAt})
+	if err != nil {
+		return err
+	}
+
+	resp := c.Request("POST", "/set-user-status", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if !resp.Ok {
+		return fmt.Errorf("could not set user status, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	return ParseEncryptedResponse(resp.JSON)
+}
+
+// SetOwnerPlan caps what an owner can create, a limit of 0 is unlimited.
+func (c *Client) SetOwnerPlan(ownerID string, plan Plan) error {
+	payload, err := json.Marshal(map[string]any{
+		"owner_id":           ownerID,
+		"max_applications":   plan.MaxApplications,
+		"max_licenses":       plan.MaxLicenses,
+		"max_active_devices": plan.MaxActiveDevices,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp := c.Request("POST", "/set-owner-plan", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if !resp.Ok {
+		return fmt.Errorf("could not set owner plan, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	return ParseEncryptedResponse(resp.JSON)
+}
+
+// ListUsers pages through every account, filter may be nil to list everyone.
+func (c *Client) ListUsers(filter *UserFilter) (*UserList, error) {
+	if filter == nil {
+		filter = &UserFilter{}
+	}
+
+	payload, err := json.Marshal(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := c.Request("POST", "/list-users", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	if !resp.Ok {
+		return nil, fmt.Errorf("could not list users, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return nil, err
+	}
+
+	var list UserList
+	if err := mapstructure.Decode(resp.JSON, &list); err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// ForceLogout signs a user out everywhere, their access tokens stop working immediately.
+func (c *Client) ForceLogout(userID string) error {
+	payload, err := json.Marshal(map[string]any{"user_id": userID})
+	if err != nil {
+		return err
+	}
+
+	resp := c.Request("POST", "/force-logout", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if !resp.Ok {
+		return fmt.Errorf("could not force logout, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	return ParseEncryptedResponse(resp.JSON)
+}
+
+// ListAuditLogs pages through role changes, suspensions, forced logouts and lockouts, newest first.
+func (c *Client) ListAuditLogs(filter *AuditLogFilter) (*AuditLogList, error) {
+	if filter == nil {
+		filter = &AuditLogFilter{}
+	}
+
+	payload, err := json.Marshal(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := c.Request("POST", "/list-audit-logs", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	if !resp.Ok {
+		return nil, fmt.Errorf("could not list audit logs, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return nil, err
+	}
+
+	var list AuditLogList
+	if err := mapstructure.Decode(resp.JSON, &list); err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
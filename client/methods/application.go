@@ -0,0 +1,143 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+
+	types "github.com/Aran404/Goauth/internal/types"
+	"github.com/mitchellh/mapstructure"
+)
+
+// AddBuild registers the SHA-256 hash of a shipped binary, see GetSignature in the client package.
+func (c *Client) AddBuild(appID, version, platform, hash string) error {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID, "app_id": appID, "version": version, "platform": platform, "hash": hash})
+	if err != nil {
+		return err
+	}
+
+	resp := c.Request("POST", "/add-build", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if !resp.Ok {
+		return fmt.Errorf("could not add build, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	return ParseEncryptedResponse(resp.JSON)
+}
+
+// UpdateBuild changes the status of a build to active, deprecated or blocked.
+func (c *Client) UpdateBuild(appID, hash, status string) error {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID, "app_id": appID, "hash": hash, "status": status})
+	if err != nil {
+		return err
+	}
+
+	resp := c.Request("POST", "/update-build", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if !resp.Ok {
+		return fmt.Errorf("could not update build, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	return ParseEncryptedResponse(resp.JSON)
+}
+
+func (c *Client) ListBuilds(appID string) ([]Build, error) {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID, "app_id": appID})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := c.Request("POST", "/list-builds", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	if !resp.Ok {
+		return nil, fmt.Errorf("could not list builds, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return nil, err
+	}
+
+	var builds []Build
+	if err := mapstructure.Decode(resp.JSON["builds"], &builds); err != nil {
+		return nil, err
+	}
+
+	return builds, nil
+}
+
+// SetApplicationStatus flips the kill switch, status is one of active, disabled or maintenance.
+func (c *Client) SetApplicationStatus(appID, status, message string) error {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID, "app_id": appID, "status": status, "message": message})
+	if err != nil {
+		return err
+	}
+
+	resp := c.Request("POST", "/set-application-status", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if !resp.Ok {
+		return fmt.Errorf("could not set application status, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	return ParseEncryptedResponse(resp.JSON)
+}
+
+func (c *Client) GetPolicy(appID string) (*Policy, error) {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID, "app_id": appID})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := c.Request("POST", "/get-policy", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	if !resp.Ok {
+		return nil, fmt.Errorf("could not get policy, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return nil, err
+	}
+
+	var policy Policy
+	if err := mapstructure.Decode(resp.JSON["policy"], &policy); err != nil {
+		return nil, err
+	}
+
+	return &policy, nil
+}
+
+// UpdatePolicy replaces the whole policy, fetch it with GetPolicy first to change a single setting.
+func (c *Client) UpdatePolicy(appID string, policy *Policy) error {
+	if policy == nil {
+		return types.ErrorEmptyStruct
+	}
+
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID, "app_id": appID, "policy": policy})
+	if err != nil {
+		return err
+	}
+
+	resp := c.Request("POST", "/update-policy", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if !resp.Ok {
+		return fmt.Errorf("could not update policy, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	return ParseEncryptedResponse(resp.JSON)
+}
@@ -0,0 +1,92 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// DeleteLicense archives a license, the returned archive ID is empty when permanent.
+func (c *Client) DeleteLicense(appID, licenseID string, permanent bool) (string, error) {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID, "app_id": appID, "license_id": licenseID, "permanent": permanent})
+	if err != nil {
+		return "", err
+	}
+
+	return c.deleteRequest("/delete-license", "license", payload)
+}
+
+// DeleteApplication archives an application with its licenses, variables and webhooks.
+func (c *Client) DeleteApplication(appID string, permanent bool) (string, error) {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID, "app_id": appID, "permanent": permanent})
+	if err != nil {
+		return "", err
+	}
+
+	return c.deleteRequest("/delete-application", "application", payload)
+}
+
+func (c *Client) deleteRequest(path, kind string, payload []byte) (string, error) {
+	resp := c.Request("POST", path, payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return "", resp.Error
+	}
+
+	if !resp.Ok {
+		return "", fmt.Errorf("could not delete %v, status code: %v, body: %v", kind, resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return "", err
+	}
+
+	id, _ := resp.JSON["archive_id"].(string)
+	return id, nil
+}
+
+// ListArchive returns the deletions of an owner that can still be restored.
+func (c *Client) ListArchive(ownerID string) ([]Archive, error) {
+	payload, err := json.Marshal(map[string]any{"owner_id": ownerID})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := c.Request("POST", "/list-archive", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	if !resp.Ok {
+		return nil, fmt.Errorf("could not list archive, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return nil, err
+	}
+
+	var archive []Archive
+	if err := mapstructure.Decode(resp.JSON["archive"], &archive); err != nil {
+		return nil, err
+	}
+
+	return archive, nil
+}
+
+func (c *Client) RestoreArchive(ownerID, archiveID string) error {
+	payload, err := json.Marshal(map[string]any{"owner_id": ownerID, "archive_id": archiveID})
+	if err != nil {
+		return err
+	}
+
+	resp := c.Request("POST", "/restore-archive", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if !resp.Ok {
+		return fmt.Errorf("could not restore archive, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	return ParseEncryptedResponse(resp.JSON)
+}
@@ -0,0 +1,65 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// SetEmail adds or changes the email of the account and sends a verification token to it.
+func (c *Client) SetEmail(password, email string) error {
+	payload, err := json.Marshal(map[string]any{"password": password, "email": email})
+	if err != nil {
+		return err
+	}
+
+	return c.emailRequest("/set-email", "set email", payload, c.authHeaders())
+}
+
+// ResendVerification sends a new verification token to the email of the account.
+func (c *Client) ResendVerification() error {
+	return c.emailRequest("/resend-verification", "resend verification", nil, c.authHeaders())
+}
+
+// VerifyEmail uses the token from the verification email, it doesn't need to be logged in.
+func (c *Client) VerifyEmail(token string) error {
+	payload, err := json.Marshal(map[string]any{"token": token})
+	if err != nil {
+		return err
+	}
+
+	return c.emailRequest("/verify-email", "verify email", payload)
+}
+
+// ForgotPassword emails a reset token if an account has the email verified, it succeeds either way.
+func (c *Client) ForgotPassword(email string) error {
+	payload, err := json.Marshal(map[string]any{"email": email})
+	if err != nil {
+		return err
+	}
+
+	return c.emailRequest("/forgot-password", "request password reset", payload)
+}
+
+// ResetPassword sets a new password with the token from the reset email, every session is signed out.
+func (c *Client) ResetPassword(token, newPassword string) error {
+	payload, err := json.Marshal(map[string]any{"token": token, "new_password": newPassword})
+	if err != nil {
+		return err
+	}
+
+	return c.emailRequest("/reset-password", "reset password", payload)
+}
+
+func (c *Client) emailRequest(path, action string, payload []byte, headers ...http.Header) error {
+	resp := c.Request("POST", path, payload, true, headers...)
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if !resp.Ok {
+		return fmt.Errorf("could not %v, status code: %v, body: %v", action, resp.Status, string(resp.Body))
+	}
+
+	return ParseEncryptedResponse(resp.JSON)
+}
@@ -2,34 +2,116 @@ package sdk
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	types "github.com/Aran404/Goauth/internal/types"
+	utils "github.com/Aran404/Goauth/internal/utils"
+	"github.com/mitchellh/mapstructure"
 )
 
 func (c *Client) License(d *LicenseValidate) error {
+	_, err := c.Validate(d)
+	return err
+}
+
+// Validate validates a license and returns what the owner has chosen to share with the client.
+func (c *Client) Validate(d *LicenseValidate) (*Validation, error) {
 	if d == nil {
-		return types.ErrorEmptyStruct
+		return nil, types.ErrorEmptyStruct
+	}
+
+	// No need to spend a handshake on a key that was mistyped
+	if !d.LegacyKey && !utils.ValidLicenseChecksum(d.LicenseKey, d.AppID) {
+		return nil, types.ErrorMalformedLicense
 	}
 
 	body, err := json.Marshal(d)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	response := c.Request("POST", "/license", body, true)
 	if response.Error != nil {
-		return response.Error
+		return nil, response.Error
 	}
 
 	if !response.Ok {
+		if err := applicationStatus(response.JSON); err != nil {
+			return nil, err
+		}
+
 		if v, ok := response.JSON["error"]; ok {
-			return fmt.Errorf("could not validate license, status code: %v, error: %v", response.Status, v)
+			return nil, fmt.Errorf("could not validate license, status code: %v, error: %v", response.Status, v)
 		}
 
-		return fmt.Errorf("could not validate license, status code: %v, body: %v", response.Status, string(response.Body))
+		return nil, fmt.Errorf("could not validate license, status code: %v, body: %v", response.Status, string(response.Body))
 	}
-	
+
 	c.H.CloseIdleConnections()
-	return ParseEncryptedResponse(response.JSON)
+	if err := applicationStatus(response.JSON); err != nil {
+		return nil, err
+	}
+
+	if err := ParseEncryptedResponse(response.JSON); err != nil {
+		return nil, err
+	}
+
+	var data Validation
+	if err := mapstructure.Decode(response.JSON, &data); err != nil {
+		return nil, err
+	}
+
+	return &data, nil
+}
+
+// applicationStatus surfaces the owner's kill switch as a distinct error, use errors.Is to check for it.
+// The owner's message is meant to be shown to the end user.
+func applicationStatus(body map[string]any) error {
+	reason, _ := body["error"].(string)
+	message, _ := body["message"].(string)
+
+	for _, v := range []error{types.ErrorApplicationDisabled, types.ErrorApplicationMaintenance} {
+		if reason == types.ProperError(v) {
+			return types.WithMessage(v, message)
+		}
+	}
+
+	return nil
+}
+
+// GetVariable fetches one of the application's variables, the license must have been validated on this session first.
+func (c *Client) GetVariable(name string) (string, error) {
+	payload, err := json.Marshal(map[string]any{"name": name})
+	if err != nil {
+		return "", err
+	}
+
+	resp := c.Request("POST", "/variable", payload, true)
+	if resp.Error != nil {
+		return "", resp.Error
+	}
+
+	if !resp.Ok {
+		if err := applicationStatus(resp.JSON); err != nil {
+			return "", err
+		}
+
+		return "", fmt.Errorf("could not get variable, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := applicationStatus(resp.JSON); err != nil {
+		return "", err
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return "", err
+	}
+
+	value, ok := resp.JSON["value"].(string)
+	if !ok {
+		return "", errors.New("improper response from server")
+	}
+
+	return value, nil
 }
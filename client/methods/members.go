@@ -0,0 +1,136 @@
+package sdk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// InviteMember returns a single use invitation token to hand to the new member, no app IDs means every application.
+func (c *Client) InviteMember(role string, appIDs ...string) (string, error) {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID, "role": role, "app_ids": appIDs})
+	if err != nil {
+		return "", err
+	}
+
+	resp := c.Request("POST", "/invite-member", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return "", resp.Error
+	}
+
+	if !resp.Ok {
+		return "", fmt.Errorf("could not invite member, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return "", err
+	}
+
+	token, ok := resp.JSON["token"].(string)
+	if !ok {
+		return "", errors.New("improper response from server")
+	}
+
+	return token, nil
+}
+
+// AcceptInvitation joins the owner that created the token and returns its ID.
+func (c *Client) AcceptInvitation(token string) (string, error) {
+	payload, err := json.Marshal(map[string]any{"token": token})
+	if err != nil {
+		return "", err
+	}
+
+	resp := c.Request("POST", "/accept-invitation", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return "", resp.Error
+	}
+
+	if !resp.Ok {
+		return "", fmt.Errorf("could not accept invitation, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return "", err
+	}
+
+	ownerID, ok := resp.JSON["owner_id"].(string)
+	if !ok {
+		return "", errors.New("improper response from server")
+	}
+
+	return ownerID, nil
+}
+
+func (c *Client) ListMembers() ([]Member, []Invitation, error) {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp := c.Request("POST", "/list-members", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return nil, nil, resp.Error
+	}
+
+	if !resp.Ok {
+		return nil, nil, fmt.Errorf("could not list members, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		members     []Member
+		invitations []Invitation
+	)
+
+	if err := mapstructure.Decode(resp.JSON["members"], &members); err != nil {
+		return nil, nil, err
+	}
+
+	if err := mapstructure.Decode(resp.JSON["invitations"], &invitations); err != nil {
+		return nil, nil, err
+	}
+
+	return members, invitations, nil
+}
+
+func (c *Client) RemoveMember(userID string) error {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID, "user_id": userID})
+	if err != nil {
+		return err
+	}
+
+	resp := c.Request("POST", "/remove-member", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if !resp.Ok {
+		return fmt.Errorf("could not remove member, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	return ParseEncryptedResponse(resp.JSON)
+}
+
+func (c *Client) RevokeInvitation(invitationID string) error {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID, "invitation_id": invitationID})
+	if err != nil {
+		return err
+	}
+
+	resp := c.Request("POST", "/revoke-invitation", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if !resp.Ok {
+		return fmt.Errorf("could not revoke invitation, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	return ParseEncryptedResponse(resp.JSON)
+}
@@ -0,0 +1,205 @@
+package sdk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// CreateReseller lets a user create licenses for the owner's applications up to the given quotas, using only the given templates.
+func (c *Client) CreateReseller(userID, name string, quotas []Quota, templates []Template) (string, error) {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID, "user_id": userID, "name": name, "quotas": quotas, "templates": templates})
+	if err != nil {
+		return "", err
+	}
+
+	resp := c.Request("POST", "/create-reseller", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return "", resp.Error
+	}
+
+	if !resp.Ok {
+		return "", fmt.Errorf("could not create reseller, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return "", err
+	}
+
+	id, ok := resp.JSON["id"].(string)
+	if !ok {
+		return "", errors.New("improper response from server")
+	}
+
+	return id, nil
+}
+
+// UpdateReseller changes whatever is set, licenses already created still count against new quotas.
+func (c *Client) UpdateReseller(resellerID string, quotas []Quota, templates []Template, disabled *bool) error {
+	body := map[string]any{"owner_id": c.OwnerID, "reseller_id": resellerID}
+	if quotas != nil {
+		body["quotas"] = quotas
+	}
+
+	if templates != nil {
+		body["templates"] = templates
+	}
+
+	if disabled != nil {
+		body["disabled"] = *disabled
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	resp := c.Request("POST", "/update-reseller", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if !resp.Ok {
+		return fmt.Errorf("could not update reseller, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	return ParseEncryptedResponse(resp.JSON)
+}
+
+func (c *Client) ListResellers() ([]Reseller, error) {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := c.Request("POST", "/list-resellers", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	if !resp.Ok {
+		return nil, fmt.Errorf("could not list resellers, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return nil, err
+	}
+
+	var resellers []Reseller
+	if err := mapstructure.Decode(resp.JSON["resellers"], &resellers); err != nil {
+		return nil, err
+	}
+
+	return resellers, nil
+}
+
+func (c *Client) DeleteReseller(resellerID string) error {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID, "reseller_id": resellerID})
+	if err != nil {
+		return err
+	}
+
+	resp := c.Request("POST", "/delete-reseller", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if !resp.Ok {
+		return fmt.Errorf("could not delete reseller, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	return ParseEncryptedResponse(resp.JSON)
+}
+
+// ResellerUsage returns how many licenses a reseller created and how many of those were activated, per application.
+func (c *Client) ResellerUsage(resellerID string) ([]ResellerUsage, error) {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID, "reseller_id": resellerID})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := c.Request("POST", "/reseller-usage", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	if !resp.Ok {
+		return nil, fmt.Errorf("could not get reseller usage, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return nil, err
+	}
+
+	var usage []ResellerUsage
+	if err := mapstructure.Decode(resp.JSON["usage"], &usage); err != nil {
+		return nil, err
+	}
+
+	return usage, nil
+}
+
+// ResellerInfo returns the quotas and templates of the signed in reseller.
+func (c *Client) ResellerInfo() (*Reseller, error) {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := c.Request("POST", "/reseller-info", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	if !resp.Ok {
+		return nil, fmt.Errorf("could not get reseller info, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return nil, err
+	}
+
+	var reseller Reseller
+	if err := mapstructure.Decode(resp.JSON["reseller"], &reseller); err != nil {
+		return nil, err
+	}
+
+	return &reseller, nil
+}
+
+// ResellerCreateLicense creates a license from one of the reseller's templates, using up one of their quota.
+func (c *Client) ResellerCreateLicense(appID, template string, metadata map[string]string, visibleMetadata []string, notes string) (string, error) {
+	payload, err := json.Marshal(map[string]any{
+		"owner_id":         c.OwnerID,
+		"app_id":           appID,
+		"template":         template,
+		"metadata":         metadata,
+		"visible_metadata": visibleMetadata,
+		"notes":            notes,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	resp := c.Request("POST", "/reseller-create-license", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return "", resp.Error
+	}
+
+	if !resp.Ok {
+		return "", fmt.Errorf("could not create license, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return "", err
+	}
+
+	key, ok := resp.JSON["key"].(string)
+	if !ok {
+		return "", errors.New("improper response from server")
+	}
+
+	return key, nil
+}
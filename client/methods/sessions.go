@@ -0,0 +1,68 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// ListSessions returns the devices the user is signed in on, the one this client uses is marked as current.
+func (c *Client) ListSessions() ([]DeviceSession, error) {
+	resp := c.Request("POST", "/list-sessions", nil, true, c.authHeaders())
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	if !resp.Ok {
+		return nil, fmt.Errorf("could not list sessions, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return nil, err
+	}
+
+	var sessions []DeviceSession
+	if err := mapstructure.Decode(resp.JSON["sessions"], &sessions); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func (c *Client) RevokeSession(sessionID string) error {
+	payload, err := json.Marshal(map[string]any{"session_id": sessionID})
+	if err != nil {
+		return err
+	}
+
+	resp := c.Request("POST", "/revoke-session", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if !resp.Ok {
+		return fmt.Errorf("could not revoke session, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	return ParseEncryptedResponse(resp.JSON)
+}
+
+// RevokeSessions signs out every device, keepCurrent leaves this client signed in.
+func (c *Client) RevokeSessions(keepCurrent bool) error {
+	payload, err := json.Marshal(map[string]any{"keep_current": keepCurrent})
+	if err != nil {
+		return err
+	}
+
+	resp := c.Request("POST", "/revoke-sessions", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if !resp.Ok {
+		return fmt.Errorf("could not revoke sessions, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	return ParseEncryptedResponse(resp.JSON)
+}
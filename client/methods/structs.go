@@ -13,16 +13,50 @@ type Client struct {
 	*http.Client
 	auth    *LoginInfo
 	OwnerID string
+	// APIToken is sent instead of the login tokens when set
+	APIToken string
+	// Device names the sessions this client starts, the user agent is used when empty
+	Device string
 }
 
 type License struct {
-	OwnerID       string `json:"owner_id"`
-	Expiry        uint64 `json:"expiry"`
-	AppID         string `json:"app_id"`
-	AppName       string `json:"name"`
-	Mask          string `json:"mask,omitempty"`
-	OnlyCapitals  bool   `json:"include_capitals,omitempty"`
-	OnlyLowercase bool   `json:"include_lowercase,omitempty"`
+	OwnerID         string            `json:"owner_id"`
+	Expiry          uint64            `json:"expiry"`
+	AppID           string            `json:"app_id"`
+	AppName         string            `json:"name"`
+	Mask            string            `json:"mask,omitempty"`
+	OnlyCapitals    bool              `json:"include_capitals,omitempty"`
+	OnlyLowercase   bool              `json:"include_lowercase,omitempty"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+	VisibleMetadata []string          `json:"visible_metadata,omitempty"`
+	Notes           string            `json:"notes,omitempty"`
+	Tier            string            `json:"tier,omitempty"`
+}
+
+type LicenseUpdate struct {
+	OwnerID         string            `json:"owner_id"`
+	AppID           string            `json:"app_id"`
+	LicenseID       string            `json:"license_id"`
+	Metadata        map[string]string `json:"metadata,omitempty"`
+	VisibleMetadata []string          `json:"visible_metadata,omitempty"`
+	Notes           *string           `json:"notes,omitempty"`
+	Tier            *string           `json:"tier,omitempty"`
+}
+
+type LicenseInfo struct {
+	ID              string            `mapstructure:"id"`
+	AppID           string            `mapstructure:"app_id"`
+	Hint            string            `mapstructure:"hint"`
+	Fingerprint     *string           `mapstructure:"fingerprint"`
+	ExpectedExpiry  uint64            `mapstructure:"expected_expiry"`
+	Expiry          *uint64           `mapstructure:"expiry"`
+	Metadata        map[string]string `mapstructure:"metadata"`
+	VisibleMetadata []string          `mapstructure:"visible_metadata"`
+	Notes           string            `mapstructure:"notes"`
+	Revoked         bool              `mapstructure:"revoked"`
+	Tier            string            `mapstructure:"tier"`
+	CreatedBy       string            `mapstructure:"created_by"`
+	ResellerID      string            `mapstructure:"reseller_id"`
 }
 
 type LicenseValidate struct {
@@ -31,11 +65,136 @@ type LicenseValidate struct {
 	AppID              string `json:"app_id"`
 	OwnerID            string `json:"owner_id"`
 	LicenseKey         string `json:"license_key"`
+	// LegacyKey skips the offline checksum for keys issued before they carried one
+	LegacyKey bool `json:"-"`
+}
+
+type LicenseFilter struct {
+	OwnerID        string            `json:"owner_id"`
+	AppID          string            `json:"app_id"`
+	Status         string            `json:"status,omitempty"`
+	Activated      *bool             `json:"activated,omitempty"`
+	ExpiringBefore uint64            `json:"expiring_before,omitempty"`
+	Fingerprint    string            `json:"fingerprint,omitempty"`
+	ResellerID     string            `json:"reseller_id,omitempty"`
+	Metadata       map[string]string `json:"metadata,omitempty"`
+	Sort           string            `json:"sort,omitempty"`
+	Descending     bool              `json:"descending,omitempty"`
+	Page           int64             `json:"page,omitempty"`
+	Limit          int64             `json:"limit,omitempty"`
+}
+
+type LicenseList struct {
+	Licenses []LicenseInfo `mapstructure:"licenses"`
+	Total    int64         `mapstructure:"total"`
+	Page     int64         `mapstructure:"page"`
+	Limit    int64         `mapstructure:"limit"`
+}
+
+type Build struct {
+	Version   string `mapstructure:"version"`
+	Platform  string `mapstructure:"platform"`
+	Hash      string `mapstructure:"hash"`
+	Status    string `mapstructure:"status"`
+	CreatedAt int64  `mapstructure:"created_at"`
+}
+
+type Webhook struct {
+	ID        string   `mapstructure:"id"`
+	URL       string   `mapstructure:"url"`
+	Events    []string `mapstructure:"events"`
+	CreatedAt int64    `mapstructure:"created_at"`
+}
+
+type WebhookDelivery struct {
+	ID           string `mapstructure:"_id"`
+	WebhookID    string `mapstructure:"webhook_id"`
+	Event        string `mapstructure:"event"`
+	Payload      string `mapstructure:"payload"`
+	Status       string `mapstructure:"status"`
+	Attempts     int    `mapstructure:"attempts"`
+	NextAttempt  int64  `mapstructure:"next_attempt"`
+	LastError    string `mapstructure:"last_error"`
+	ResponseCode int    `mapstructure:"response_code"`
+	CreatedAt    int64  `mapstructure:"created_at"`
+	DeliveredAt  int64  `mapstructure:"delivered_at"`
+}
+
+type Variable struct {
+	Name      string   `mapstructure:"name"`
+	Value     string   `mapstructure:"value"`
+	Tiers     []string `mapstructure:"tiers"`
+	UpdatedAt int64    `mapstructure:"updated_at"`
+}
+
+type AccessRule struct {
+	ID        string `mapstructure:"_id"`
+	Kind      string `mapstructure:"kind"`
+	Value     string `mapstructure:"value"`
+	Action    string `mapstructure:"action"`
+	Reason    string `mapstructure:"reason"`
+	ExpiresAt int64  `mapstructure:"expires_at"`
+	Hits      int64  `mapstructure:"hits"`
+	LastHit   int64  `mapstructure:"last_hit"`
+	CreatedAt int64  `mapstructure:"created_at"`
+}
+
+type Archive struct {
+	ID              string `mapstructure:"id"`
+	Kind            string `mapstructure:"kind"`
+	TargetID        string `mapstructure:"target_id"`
+	DeletedAt       int64  `mapstructure:"deleted_at"`
+	RestorableUntil int64  `mapstructure:"restorable_until"`
+}
+
+type Member struct {
+	UserID   string   `mapstructure:"user_id"`
+	Username string   `mapstructure:"username"`
+	Role     string   `mapstructure:"role"`
+	AppIDs   []string `mapstructure:"app_ids"`
+	AddedAt  int64    `mapstructure:"added_at"`
+	Primary  bool     `mapstructure:"primary"`
+}
+
+type Invitation struct {
+	ID        string   `mapstructure:"id"`
+	Role      string   `mapstructure:"role"`
+	AppIDs    []string `mapstructure:"app_ids"`
+	ExpiresAt int64    `mapstructure:"expires_at"`
+}
+
+type APIToken struct {
+	ID        string   `mapstructure:"id"`
+	Name      string   `mapstructure:"name"`
+	Hint      string   `mapstructure:"hint"`
+	UserID    string   `mapstructure:"user_id"`
+	Scopes    []string `mapstructure:"scopes"`
+	ExpiresAt int64    `mapstructure:"expires_at"`
+	LastUsed  int64    `mapstructure:"last_used"`
+	CreatedAt int64    `mapstructure:"created_at"`
+}
+
+type Policy struct {
+	EnforceFingerprint   bool   `json:"enforce_fingerprint" mapstructure:"enforce_fingerprint"`
+	EnforceIntegrity     bool   `json:"enforce_integrity" mapstructure:"enforce_integrity"`
+	AllowTrustOnFirstUse bool   `json:"allow_trust_on_first_use" mapstructure:"allow_trust_on_first_use"`
+	MaxDevices           int    `json:"max_devices" mapstructure:"max_devices"`
+	MaxIPs               int    `json:"max_ips" mapstructure:"max_ips"`
+	OfflineGracePeriod   uint64 `json:"offline_grace_period" mapstructure:"offline_grace_period"`
 }
 
+type Validation struct {
+	Metadata     map[string]string `mapstructure:"metadata"`
+	Deprecated   bool              `mapstructure:"deprecated"`
+	OfflineUntil int64             `mapstructure:"offline_until"`
+}
+
+// LoginInfo only carries a Ticket when the account has two-factor enabled, pass it to LoginTwoFactor
 type LoginInfo struct {
 	RefreshToken string `mapstructure:"refresh_token"`
 	Token        string `mapstructure:"token"`
+	TwoFactor    bool   `mapstructure:"two_factor"`
+	Ticket       string `mapstructure:"ticket"`
 }
 
 func NewClient(c *websocket.Client, host string) *Client {
@@ -70,3 +229,117 @@ func ParseEncryptedResponse(body map[string]any) error {
 
 	return nil
 }
+
+type Reseller struct {
+	ID        string     `mapstructure:"_id"`
+	OwnerID   string     `mapstructure:"owner_id"`
+	UserID    string     `mapstructure:"user_id"`
+	Name      string     `mapstructure:"name"`
+	Quotas    []Quota    `mapstructure:"quotas"`
+	Templates []Template `mapstructure:"templates"`
+	Disabled  bool       `mapstructure:"disabled"`
+	CreatedAt int64      `mapstructure:"created_at"`
+}
+
+// Quota is how many licenses a reseller may create for an application, only the limit is sent when setting it
+type Quota struct {
+	AppID     string `json:"app_id" mapstructure:"app_id"`
+	Limit     int64  `json:"limit" mapstructure:"limit"`
+	Used      int64  `json:"-" mapstructure:"used"`
+	Remaining int64  `json:"-" mapstructure:"remaining"`
+}
+
+type Template struct {
+	Name   string `json:"name" mapstructure:"name"`
+	Expiry uint64 `json:"expiry" mapstructure:"expiry"`
+	Tier   string `json:"tier" mapstructure:"tier"`
+	Mask   string `json:"mask" mapstructure:"mask"`
+}
+
+type ResellerUsage struct {
+	AppID     string `mapstructure:"app_id"`
+	Limit     int64  `mapstructure:"limit"`
+	Used      int64  `mapstructure:"used"`
+	Remaining int64  `mapstructure:"remaining"`
+	Created   int64  `mapstructure:"created"`
+	Activated int64  `mapstructure:"activated"`
+}
+
+// Plan limits what an owner can create, a limit of 0 is unlimited
+type Plan struct {
+	MaxApplications  int64 `mapstructure:"max_applications"`
+	MaxLicenses      int64 `mapstructure:"max_licenses"`
+	MaxActiveDevices int64 `mapstructure:"max_active_devices"`
+}
+
+type PlanUsage struct {
+	Applications  int64 `mapstructure:"applications"`
+	Licenses      int64 `mapstructure:"licenses"`
+	ActiveDevices int64 `mapstructure:"active_devices"`
+}
+
+type UserFilter struct {
+	Query     string `json:"query,omitempty"`
+	Role      string `json:"role,omitempty"`
+	Suspended *bool  `json:"suspended,omitempty"`
+	Page      int64  `json:"page,omitempty"`
+	Limit     int64  `json:"limit,omitempty"`
+}
+
+type User struct {
+	ID            string   `mapstructure:"id"`
+	Username      string   `mapstructure:"username"`
+	Email         string   `mapstructure:"email"`
+	EmailVerified bool     `mapstructure:"email_verified"`
+	Role          string   `mapstructure:"role"`
+	Permissions   []string `mapstructure:"permissions"`
+	TwoFactor     bool     `mapstructure:"two_factor"`
+	Suspended     bool     `mapstructure:"suspended"`
+	SuspendReason string   `mapstructure:"suspend_reason"`
+	ExpiresAt     int64    `mapstructure:"expires_at"`
+	CreatedAt     int64    `mapstructure:"created_at"`
+}
+
+type UserList struct {
+	Users []User `mapstructure:"users"`
+	Total int64  `mapstructure:"total"`
+	Page  int64  `mapstructure:"page"`
+	Limit int64  `mapstructure:"limit"`
+}
+
+type AuditLogFilter struct {
+	Action   string `json:"action,omitempty"`
+	TargetID string `json:"target_id,omitempty"`
+	ActorID  string `json:"actor_id,omitempty"`
+	Page     int64  `json:"page,omitempty"`
+	Limit    int64  `json:"limit,omitempty"`
+}
+
+type AuditLog struct {
+	ID        string            `mapstructure:"_id"`
+	ActorID   string            `mapstructure:"actor_id"`
+	Actor     string            `mapstructure:"actor"`
+	Action    string            `mapstructure:"action"`
+	TargetID  string            `mapstructure:"target_id"`
+	Target    string            `mapstructure:"target"`
+	Details   map[string]string `mapstructure:"details"`
+	IP        string            `mapstructure:"ip"`
+	CreatedAt int64             `mapstructure:"created_at"`
+}
+
+type AuditLogList struct {
+	Logs  []AuditLog `mapstructure:"logs"`
+	Total int64      `mapstructure:"total"`
+	Page  int64      `mapstructure:"page"`
+	Limit int64      `mapstructure:"limit"`
+}
+
+type DeviceSession struct {
+	ID        string `mapstructure:"id"`
+	Device    string `mapstructure:"device"`
+	IP        string `mapstructure:"ip"`
+	CreatedAt int64  `mapstructure:"created_at"`
+	LastUsed  int64  `mapstructure:"last_used"`
+	ExpiresAt int64  `mapstructure:"expires_at"`
+	Current   bool   `mapstructure:"current"`
+}
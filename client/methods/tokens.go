@@ -0,0 +1,87 @@
+package sdk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// CreateAPIToken returns the ID and the token, which is only shown once. An expiry of 0 never expires.
+func (c *Client) CreateAPIToken(name string, scopes []string, expiresAt int64) (string, string, error) {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID, "name": name, "scopes": scopes, "expires_at": expiresAt})
+	if err != nil {
+		return "", "", err
+	}
+
+	resp := c.Request("POST", "/create-api-token", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return "", "", resp.Error
+	}
+
+	if !resp.Ok {
+		return "", "", fmt.Errorf("could not create api token, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return "", "", err
+	}
+
+	id, ok := resp.JSON["id"].(string)
+	if !ok {
+		return "", "", errors.New("improper response from server")
+	}
+
+	token, ok := resp.JSON["token"].(string)
+	if !ok {
+		return "", "", errors.New("improper response from server")
+	}
+
+	return id, token, nil
+}
+
+func (c *Client) ListAPITokens() ([]APIToken, error) {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := c.Request("POST", "/list-api-tokens", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	if !resp.Ok {
+		return nil, fmt.Errorf("could not list api tokens, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return nil, err
+	}
+
+	var tokens []APIToken
+	if err := mapstructure.Decode(resp.JSON["tokens"], &tokens); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+func (c *Client) RevokeAPIToken(tokenID string) error {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID, "token_id": tokenID})
+	if err != nil {
+		return err
+	}
+
+	resp := c.Request("POST", "/revoke-api-token", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if !resp.Ok {
+		return fmt.Errorf("could not revoke api token, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	return ParseEncryptedResponse(resp.JSON)
+}
@@ -0,0 +1,123 @@
+package sdk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// EnrollTwoFactor returns the TOTP secret and its otpauth URI, two-factor is enabled once ConfirmTwoFactor succeeds.
+func (c *Client) EnrollTwoFactor(password string) (string, string, error) {
+	payload, err := json.Marshal(map[string]any{"password": password})
+	if err != nil {
+		return "", "", err
+	}
+
+	resp := c.Request("POST", "/enroll-2fa", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return "", "", resp.Error
+	}
+
+	if !resp.Ok {
+		return "", "", fmt.Errorf("could not enroll two-factor, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return "", "", err
+	}
+
+	secret, ok := resp.JSON["secret"].(string)
+	if !ok {
+		return "", "", errors.New("improper response from server")
+	}
+
+	uri, ok := resp.JSON["uri"].(string)
+	if !ok {
+		return "", "", errors.New("improper response from server")
+	}
+
+	return secret, uri, nil
+}
+
+// ConfirmTwoFactor enables two-factor and returns the recovery codes, which are only shown once.
+// The client switches to the new tokens returned with them.
+func (c *Client) ConfirmTwoFactor(code string) ([]string, error) {
+	payload, err := json.Marshal(map[string]any{"code": code})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := c.Request("POST", "/confirm-2fa", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	if !resp.Ok {
+		return nil, fmt.Errorf("could not confirm two-factor, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return nil, err
+	}
+
+	var data LoginInfo
+	if err := mapstructure.Decode(resp.JSON, &data); err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	if err := mapstructure.Decode(resp.JSON["recovery_codes"], &codes); err != nil {
+		return nil, err
+	}
+
+	c.auth = &data
+	return codes, nil
+}
+
+func (c *Client) DisableTwoFactor(password, code string) error {
+	payload, err := json.Marshal(map[string]any{"password": password, "code": code})
+	if err != nil {
+		return err
+	}
+
+	resp := c.Request("POST", "/disable-2fa", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if !resp.Ok {
+		return fmt.Errorf("could not disable two-factor, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	return ParseEncryptedResponse(resp.JSON)
+}
+
+// RegenerateRecoveryCodes replaces every recovery code, the old ones stop working.
+func (c *Client) RegenerateRecoveryCodes(code string) ([]string, error) {
+	payload, err := json.Marshal(map[string]any{"code": code})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := c.Request("POST", "/recovery-codes", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	if !resp.Ok {
+		return nil, fmt.Errorf("could not regenerate recovery codes, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	if err := mapstructure.Decode(resp.JSON["recovery_codes"], &codes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
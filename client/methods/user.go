@@ -6,10 +6,12 @@ import (
 	"fmt"
 
 	types "github.com/Aran404/Goauth/internal/types"
+	"github.com/mitchellh/mapstructure"
 )
 
-func (c *Client) CreateApplication(name string) (string, error) {
-	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID, "name": name})
+// CreateApplication creates an application, pass true to pin the first build that validates.
+func (c *Client) CreateApplication(name string, trustOnFirstUse ...bool) (string, error) {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID, "name": name, "trust_on_first_use": len(trustOnFirstUse) > 0 && trustOnFirstUse[0]})
 	if err != nil {
 		return "", err
 	}
@@ -65,3 +67,151 @@ func (c *Client) CreateLicense(settings *License) (string, error) {
 
 	return license, nil
 }
+
+func (c *Client) UpdateLicense(settings *LicenseUpdate) error {
+	if settings == nil {
+		return types.ErrorEmptyStruct
+	}
+
+	payload, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+
+	resp := c.Request("POST", "/update-license", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if !resp.Ok {
+		return fmt.Errorf("could not update license, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	return ParseEncryptedResponse(resp.JSON)
+}
+
+func (c *Client) RevokeLicense(appID, licenseID string) error {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID, "app_id": appID, "license_id": licenseID})
+	if err != nil {
+		return err
+	}
+
+	resp := c.Request("POST", "/revoke-license", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if !resp.Ok {
+		return fmt.Errorf("could not revoke license, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	return ParseEncryptedResponse(resp.JSON)
+}
+
+// SearchLicenses finds licenses whose metadata matches every given pair, notes are matched as a substring.
+// Only the first page is returned, SearchLicensesPage reaches the rest.
+func (c *Client) SearchLicenses(appID string, metadata map[string]string, notes string) ([]LicenseInfo, error) {
+	list, err := c.SearchLicensesPage(appID, metadata, notes, 1, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return list.Licenses, nil
+}
+
+// SearchLicensesPage returns one page of a search, a limit of 0 uses the largest page the server allows.
+func (c *Client) SearchLicensesPage(appID string, metadata map[string]string, notes string, page, limit int64) (*LicenseList, error) {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID, "app_id": appID, "metadata": metadata, "notes": notes, "page": page, "limit": limit})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := c.Request("POST", "/search-licenses", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	if !resp.Ok {
+		return nil, fmt.Errorf("could not search licenses, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return nil, err
+	}
+
+	var list LicenseList
+	if err := mapstructure.Decode(resp.JSON, &list); err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// ListLicenses returns one page of licenses, the owner ID defaults to the client's.
+func (c *Client) ListLicenses(filter *LicenseFilter) (*LicenseList, error) {
+	if filter == nil {
+		return nil, types.ErrorEmptyStruct
+	}
+
+	if filter.OwnerID == "" {
+		filter.OwnerID = c.OwnerID
+	}
+
+	payload, err := json.Marshal(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := c.Request("POST", "/list-licenses", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	if !resp.Ok {
+		return nil, fmt.Errorf("could not list licenses, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return nil, err
+	}
+
+	var list LicenseList
+	if err := mapstructure.Decode(resp.JSON, &list); err != nil {
+		return nil, err
+	}
+
+	return &list, nil
+}
+
+// GetOwnerPlan returns the owner's limits along with what it currently uses.
+func (c *Client) GetOwnerPlan() (*Plan, *PlanUsage, error) {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp := c.Request("POST", "/owner-plan", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return nil, nil, resp.Error
+	}
+
+	if !resp.Ok {
+		return nil, nil, fmt.Errorf("could not get owner plan, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return nil, nil, err
+	}
+
+	var plan Plan
+	if err := mapstructure.Decode(resp.JSON["plan"], &plan); err != nil {
+		return nil, nil, err
+	}
+
+	var usage PlanUsage
+	if err := mapstructure.Decode(resp.JSON["usage"], &usage); err != nil {
+		return nil, nil, err
+	}
+
+	return &plan, &usage, nil
+}
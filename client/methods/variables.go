@@ -0,0 +1,72 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// SetVariable creates or replaces a variable, leave tiers empty to share it with every license.
+func (c *Client) SetVariable(appID, name, value string, tiers ...string) error {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID, "app_id": appID, "name": name, "value": value, "tiers": tiers})
+	if err != nil {
+		return err
+	}
+
+	resp := c.Request("POST", "/set-variable", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if !resp.Ok {
+		return fmt.Errorf("could not set variable, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	return ParseEncryptedResponse(resp.JSON)
+}
+
+func (c *Client) DeleteVariable(appID, name string) error {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID, "app_id": appID, "name": name})
+	if err != nil {
+		return err
+	}
+
+	resp := c.Request("POST", "/delete-variable", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if !resp.Ok {
+		return fmt.Errorf("could not delete variable, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	return ParseEncryptedResponse(resp.JSON)
+}
+
+func (c *Client) ListVariables(appID string) ([]Variable, error) {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID, "app_id": appID})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := c.Request("POST", "/list-variables", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	if !resp.Ok {
+		return nil, fmt.Errorf("could not list variables, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return nil, err
+	}
+
+	var variables []Variable
+	if err := mapstructure.Decode(resp.JSON["variables"], &variables); err != nil {
+		return nil, err
+	}
+
+	return variables, nil
+}
@@ -0,0 +1,141 @@
+package sdk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	crypto "github.com/Aran404/Goauth/internal/crypto"
+	"github.com/mitchellh/mapstructure"
+)
+
+// CreateWebhook registers a receiver for license events, no events means every event.
+// The returned secret is only shown once and signs every delivery.
+func (c *Client) CreateWebhook(appID, url string, events []string) (string, string, error) {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID, "app_id": appID, "url": url, "events": events})
+	if err != nil {
+		return "", "", err
+	}
+
+	resp := c.Request("POST", "/create-webhook", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return "", "", resp.Error
+	}
+
+	if !resp.Ok {
+		return "", "", fmt.Errorf("could not create webhook, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return "", "", err
+	}
+
+	id, ok := resp.JSON["id"].(string)
+	if !ok {
+		return "", "", errors.New("improper response from server")
+	}
+
+	secret, ok := resp.JSON["secret"].(string)
+	if !ok {
+		return "", "", errors.New("improper response from server")
+	}
+
+	return id, secret, nil
+}
+
+func (c *Client) DeleteWebhook(appID, webhookID string) error {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID, "app_id": appID, "webhook_id": webhookID})
+	if err != nil {
+		return err
+	}
+
+	resp := c.Request("POST", "/delete-webhook", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if !resp.Ok {
+		return fmt.Errorf("could not delete webhook, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	return ParseEncryptedResponse(resp.JSON)
+}
+
+func (c *Client) ListWebhooks(appID string) ([]Webhook, error) {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID, "app_id": appID})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := c.Request("POST", "/list-webhooks", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	if !resp.Ok {
+		return nil, fmt.Errorf("could not list webhooks, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return nil, err
+	}
+
+	var hooks []Webhook
+	if err := mapstructure.Decode(resp.JSON["webhooks"], &hooks); err != nil {
+		return nil, err
+	}
+
+	return hooks, nil
+}
+
+// ListWebhookDeliveries returns recent deliveries, pass the dead status to get the dead-letter list.
+func (c *Client) ListWebhookDeliveries(appID, status string, limit int64) ([]WebhookDelivery, error) {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID, "app_id": appID, "status": status, "limit": limit})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := c.Request("POST", "/list-webhook-deliveries", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+
+	if !resp.Ok {
+		return nil, fmt.Errorf("could not list webhook deliveries, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	if err := ParseEncryptedResponse(resp.JSON); err != nil {
+		return nil, err
+	}
+
+	var deliveries []WebhookDelivery
+	if err := mapstructure.Decode(resp.JSON["deliveries"], &deliveries); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func (c *Client) RetryWebhookDelivery(appID, deliveryID string) error {
+	payload, err := json.Marshal(map[string]any{"owner_id": c.OwnerID, "app_id": appID, "delivery_id": deliveryID})
+	if err != nil {
+		return err
+	}
+
+	resp := c.Request("POST", "/retry-webhook-delivery", payload, true, c.authHeaders())
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if !resp.Ok {
+		return fmt.Errorf("could not retry webhook delivery, status code: %v, body: %v", resp.Status, string(resp.Body))
+	}
+
+	return ParseEncryptedResponse(resp.JSON)
+}
+
+// VerifyWebhook checks the X-Goauth-Signature of a delivery, receivers should also reject stale timestamps.
+func VerifyWebhook(secret, timestamp, body, signature string) bool {
+	expected := crypto.KeyedHash(timestamp+"."+body, []byte(secret))
+	return crypto.VerifyHMAC([]byte(expected), []byte(signature))
+}
@@ -75,6 +75,11 @@ func (c *Client) RetrieveSession() (*string, error) {
 			return "", err
 		}
 
+		// The server refused the exchange, e.g. the client is blocked
+		if message, ok := data["error"].(string); ok {
+			return "", fmt.Errorf("key exchange refused: %v", message)
+		}
+
 		session, ok := data["session_id"].(string)
 		if !ok {
 			return "", types.ErrorInvalidHello
@@ -116,7 +121,13 @@ func (c *Client) RetrieveSession() (*string, error) {
 }
 
 func (c *Client) SendHello(pub []byte, nonce [12]byte) error {
-	staged, err := json.Marshal(map[string]any{"public": pub, "nonce": base64.StdEncoding.EncodeToString(nonce[:])})
+	hello := map[string]any{"public": pub, "nonce": base64.StdEncoding.EncodeToString(nonce[:])}
+	if c.AppID != "" {
+		hello["app_id"] = c.AppID
+		hello["fingerprint"] = c.Fingerprint
+	}
+
+	staged, err := json.Marshal(hello)
 	if err != nil {
 		return err
 	}
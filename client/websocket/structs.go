@@ -12,6 +12,10 @@ type Client struct {
 	Nonce     [12]byte
 	HashKey   [32]byte
 	SessionID string
+
+	// AppID and Fingerprint are optional, when set the server checks its access rules during the key exchange
+	AppID       string
+	Fingerprint string
 }
 
 func NewClient(port string) (*Client, error) {
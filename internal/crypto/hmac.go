@@ -2,6 +2,7 @@ package crypto
 
 import (
 	"crypto/hmac"
+	"crypto/sha256"
 	"crypto/sha512"
 	"encoding/hex"
 	"math/rand"
@@ -38,3 +39,11 @@ func GenerateHMAC(message []byte, secretKey [32]byte) string {
 func VerifyHMAC(proper, expected []byte) bool {
 	return hmac.Equal(proper, expected)
 }
+
+// KeyedHash generates an HMAC-SHA256 hash of a value using a server side pepper.
+// It is used for secrets that must be looked up by value but should never be stored in plain text.
+func KeyedHash(value string, pepper []byte) string {
+	hash := hmac.New(sha256.New, pepper)
+	hash.Write([]byte(value))
+	return hex.EncodeToString(hash.Sum(nil))
+}
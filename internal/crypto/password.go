@@ -0,0 +1,128 @@
+package crypto
+
+import (
+	"errors"
+	"runtime"
+	"time"
+)
+
+var (
+	ErrUnknownHash = errors.New("unknown password hash")
+	ErrBusy        = errors.New("no password worker free")
+)
+
+// PasswordHasher is a password hashing algorithm, Verify reads the parameters from the hash so old hashes keep working.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(password, hash string) (bool, error)
+	// Recognizes reports whether the hash was made by this algorithm
+	Recognizes(hash string) bool
+	// Outdated reports whether the hash was made with other parameters than the hasher's
+	Outdated(hash string) bool
+}
+
+// Passwords hashes with one algorithm and verifies any known one, at most a fixed number of hashes run at once.
+type Passwords struct {
+	hasher  PasswordHasher
+	known   []PasswordHasher
+	workers chan struct{}
+	wait    time.Duration
+}
+
+// NewPasswords uses one worker per CPU when workers isn't positive.
+// A hash that can't get a worker within wait fails with ErrBusy, wait defaults to 5 seconds.
+func NewPasswords(hasher PasswordHasher, workers int, wait time.Duration) *Passwords {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	if wait <= 0 {
+		wait = 5 * time.Second
+	}
+
+	return &Passwords{
+		hasher:  hasher,
+		known:   []PasswordHasher{hasher, &Argon2idHasher{}, &BcryptHasher{}},
+		workers: make(chan struct{}, workers),
+		wait:    wait,
+	}
+}
+
+// NewPasswordHasher returns the hasher for a configured algorithm name.
+func NewPasswordHasher(algorithm string, memory, iterations uint32, parallelism uint8, bcryptCost int) (PasswordHasher, error) {
+	switch algorithm {
+	case "", "argon2id":
+		hasher := DefaultArgon2id()
+		if memory > 0 {
+			hasher.Memory = memory
+		}
+
+		if iterations > 0 {
+			hasher.Iterations = iterations
+		}
+
+		if parallelism > 0 {
+			hasher.Parallelism = parallelism
+		}
+		return hasher, nil
+	case "bcrypt":
+		return NewBcryptHasher(bcryptCost), nil
+	}
+
+	return nil, errors.New("unknown password hashing algorithm: " + algorithm)
+}
+
+func (p *Passwords) acquire() (func(), error) {
+	timer := time.NewTimer(p.wait)
+	defer timer.Stop()
+
+	select {
+	case p.workers <- struct{}{}:
+		return func() { <-p.workers }, nil
+	case <-timer.C:
+		return nil, ErrBusy
+	}
+}
+
+// Hash hashes a password with the configured algorithm.
+func (p *Passwords) Hash(password string) (string, error) {
+	release, err := p.acquire()
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	return p.hasher.Hash(password)
+}
+
+// Verify checks a password against a hash of any known algorithm.
+// rehash is set when the password matched but the hash should be replaced with one of the configured algorithm.
+// err is only set when no worker was free, a password that doesn't match is not an error.
+func (p *Passwords) Verify(password, hash string) (ok bool, rehash bool, err error) {
+	release, err := p.acquire()
+	if err != nil {
+		return false, false, err
+	}
+	defer release()
+
+	for _, hasher := range p.known {
+		if !hasher.Recognizes(hash) {
+			continue
+		}
+
+		ok, err := hasher.Verify(password, hash)
+		if err != nil || !ok {
+			return false, false, nil
+		}
+
+		return true, !p.hasher.Recognizes(hash) || p.hasher.Outdated(hash), nil
+	}
+
+	return false, false, nil
+}
+
+// Check is Verify for callers that don't rehash.
+func (p *Passwords) Check(password, hash string) (bool, error) {
+	ok, _, err := p.Verify(password, hash)
+	return ok, err
+}
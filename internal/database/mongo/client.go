@@ -2,15 +2,45 @@ package mongo
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	log "github.com/Aran404/Goauth/internal/logger"
 	types "github.com/Aran404/Goauth/internal/types"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// indexes are created at startup, the unique ones settle races a check before a write can't.
+var indexes = map[string][]mongo.IndexModel{
+	Users: {
+		// Only verified emails are taken, anyone can add an address they don't own yet
+		{
+			Keys:    bson.D{{Key: "email", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"email_verified": true}),
+		},
+	},
+	AccessRules: {
+		{Keys: bson.D{{Key: "app_id", Value: 1}, {Key: "kind", Value: 1}, {Key: "value", Value: 1}}, Options: options.Index().SetUnique(true)},
+	},
+	Variables: {
+		{Keys: bson.D{{Key: "app_id", Value: 1}, {Key: "name", Value: 1}}, Options: options.Index().SetUnique(true)},
+	},
+}
+
+// EnsureIndexes creates the indexes the server relies on, existing ones are left alone.
+func (c *Connection) EnsureIndexes(ctx context.Context) error {
+	for col, models := range indexes {
+		if _, err := c.Get(col).Indexes().CreateMany(ctx, models); err != nil {
+			return fmt.Errorf("could not create indexes on %v: %w", col, err)
+		}
+	}
+
+	return nil
+}
+
 func NewConn(ctx context.Context) *Connection {
 	options := options.Client().
 		ApplyURI(types.Cfg.Mongo.Host).
@@ -9,6 +9,7 @@ import (
 	"github.com/Aran404/Goauth/internal/types"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // Create creates a new item in the collection
@@ -16,6 +17,17 @@ func (c *Connection) Create(ctx context.Context, coll string, data any) error {
 	return c.Write(ctx, coll, data)
 }
 
+// CreateMany inserts every item in one request, the driver splits it into batches when needed
+func (c *Connection) CreateMany(ctx context.Context, coll string, data []any) error {
+	_, err := c.Get(coll).InsertMany(ctx, data)
+	return err
+}
+
+// IsDuplicate reports whether a write failed because the document already exists
+func IsDuplicate(err error) bool {
+	return mongo.IsDuplicateKeyError(err)
+}
+
 // CreateCreateAndReturn creates a new item in the collection and returns it
 func (c *Connection) CreateAndReturn(ctx context.Context, coll string, data any) (*mongo.InsertOneResult, error) {
 	return c.Get(coll).InsertOne(ctx, data)
@@ -39,6 +51,38 @@ func (c *Connection) Update(ctx context.Context, name string, query, data any) e
 	return err
 }
 
+// Modify applies a raw update document and returns how many documents matched the query
+func (c *Connection) Modify(ctx context.Context, name string, query, update any) (int64, error) {
+	result, err := c.Get(name).UpdateOne(ctx, query, update)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.MatchedCount, nil
+}
+
+// Upsert applies a raw update document to the first match, creating the document from the query when nothing matches.
+// Two upserts racing on a unique index make one fail with a duplicate key, that one is run again as an update.
+func (c *Connection) Upsert(ctx context.Context, name string, query, update any) error {
+	opts := options.Update().SetUpsert(true)
+	_, err := c.Get(name).UpdateOne(ctx, query, update, opts)
+	if IsDuplicate(err) {
+		_, err = c.Get(name).UpdateOne(ctx, query, update, opts)
+	}
+
+	return err
+}
+
+// ModifyMany applies a raw update document to every match and returns how many documents matched the query
+func (c *Connection) ModifyMany(ctx context.Context, name string, query, update any) (int64, error) {
+	result, err := c.Get(name).UpdateMany(ctx, query, update)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.MatchedCount, nil
+}
+
 // Exists checks if a query matches in a collection
 func (c *Connection) Exists(ctx context.Context, name string, query any) (bool, error) {
 	coll := c.Get(name)
@@ -113,16 +157,70 @@ func (c *Connection) Filter(ctx context.Context, name string, query any, collisi
 	return Matched, nil
 }
 
+// Decode decodes the first match of the query straight into v, skipping the JSON round trip of ReadInto
+func (c *Connection) Decode(ctx context.Context, name string, query, v any, notFound ...error) error {
+	err := c.Get(name).FindOne(ctx, query).Decode(v)
+	if err == mongo.ErrNoDocuments {
+		if len(notFound) > 0 {
+			return notFound[0]
+		}
+		return types.ErrorNotFound
+	}
+
+	return err
+}
+
+// Find returns every match of the query, an empty result is not an error
+func (c *Connection) Find(ctx context.Context, name string, query any, opts ...*options.FindOptions) ([]bson.M, error) {
+	coll := c.Get(name)
+
+	cursor, err := coll.Find(ctx, query, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	Matched := []bson.M{}
+	if err := cursor.All(ctx, &Matched); err != nil {
+		return nil, err
+	}
+
+	return Matched, nil
+}
+
+// Aggregate runs a pipeline and returns every resulting document
+func (c *Connection) Aggregate(ctx context.Context, name string, pipeline any) ([]bson.M, error) {
+	cursor, err := c.Get(name).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	Matched := []bson.M{}
+	if err := cursor.All(ctx, &Matched); err != nil {
+		return nil, err
+	}
+
+	return Matched, nil
+}
+
+// Count counts the documents matching the query
+func (c *Connection) Count(ctx context.Context, name string, query any) (int64, error) {
+	return c.Get(name).CountDocuments(ctx, query)
+}
+
 // Delete deletes an item from the collection that matches the query
 func (c *Connection) Delete(ctx context.Context, name string, query any) error {
 	coll := c.Get(name)
 
 	count, err := coll.DeleteMany(ctx, query)
+	if err != nil {
+		return err
+	}
+
 	if count.DeletedCount <= 0 {
 		return types.ErrorNoMatches
 	}
 
-	return err
+	return nil
 }
 
 // Drop drops the collection
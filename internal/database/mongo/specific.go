@@ -22,6 +22,19 @@ func ReadInto[T DataTypes](data []bson.M, v *T) error {
 	return json.Unmarshal(raw, v)
 }
 
+func ReadAllInto[T DataTypes](data []bson.M, v *[]T) error {
+	if reflect.TypeOf(v).Kind() != reflect.Ptr {
+		return types.ErrorNotPointer
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(raw, v)
+}
+
 func CheckObjectArray(data *[]primitive.ObjectID, o primitive.ObjectID) bool {
 	for _, v := range *data {
 		if o == v {
@@ -1,6 +1,7 @@
 package mongo
 
 import (
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 )
@@ -10,6 +11,52 @@ const (
 	Owners       = "owners"
 	Licenses     = "licenses"
 	Users        = "users"
+
+	Webhooks          = "webhooks"
+	WebhookDeliveries = "webhook_deliveries"
+	Variables         = "variables"
+	Archive           = "archive"
+	ArchivedDocuments = "archived_documents"
+	Invitations       = "invitations"
+	APITokens         = "api_tokens"
+	Resellers         = "resellers"
+	AccessRules       = "access_rules"
+	AuditLogs         = "audit_logs"
+	RefreshSessions   = "refresh_sessions"
+)
+
+const (
+	BuildActive     = "active"
+	BuildDeprecated = "deprecated"
+	BuildBlocked    = "blocked"
+
+	AppActive      = "active"
+	AppDisabled    = "disabled"
+	AppMaintenance = "maintenance"
+
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+
+	ArchivedOwner       = "owner"
+	ArchivedApplication = "application"
+	ArchivedLicense     = "license"
+
+	RoleAdmin    = "admin"
+	RoleSupport  = "support"
+	RoleAuditor  = "auditor"
+	RoleOwner    = "owner"
+	RoleReseller = "reseller"
+
+	RuleFingerprint = "fingerprint"
+	RuleIP          = "ip"
+	RuleDeny        = "deny"
+	RuleAllow       = "allow"
+
+	AuditUserRole    = "user.role"
+	AuditUserStatus  = "user.status"
+	AuditUserLogout  = "user.logout"
+	AuditLoginLocked = "login.lockout"
 )
 
 type (
@@ -24,33 +71,314 @@ type (
 		Licenses           []primitive.ObjectID `json:"licenses" bson:"licenses"`
 		IntegritySignature *string              `json:"integrity_signature" bson:"integrity_signature"`
 		Name               string               `json:"name" bson:"name"`
+		Builds             []BuildObject        `json:"builds" bson:"builds"`
+		Policy             *PolicyObject        `json:"policy" bson:"policy"`
+		// TrustOnFirstUse is only read for applications created before policies existed
+		TrustOnFirstUse bool   `json:"trust_on_first_use,omitempty" bson:"trust_on_first_use,omitempty"`
+		Status          string `json:"status" bson:"status"`
+		StatusMessage   string `json:"status_message" bson:"status_message"`
+		// Frozen is set while the owner's account is suspended, separate from the status the owner controls
+		Frozen bool `json:"frozen" bson:"frozen"`
+	}
+
+	PolicyObject struct {
+		EnforceFingerprint   bool   `json:"enforce_fingerprint" bson:"enforce_fingerprint"`
+		EnforceIntegrity     bool   `json:"enforce_integrity" bson:"enforce_integrity"`
+		AllowTrustOnFirstUse bool   `json:"allow_trust_on_first_use" bson:"allow_trust_on_first_use"`
+		MaxDevices           int    `json:"max_devices" bson:"max_devices"`
+		MaxIPs               int    `json:"max_ips" bson:"max_ips"`
+		OfflineGracePeriod   uint64 `json:"offline_grace_period" bson:"offline_grace_period"`
+	}
+
+	BuildObject struct {
+		Version   string `json:"version" bson:"version"`
+		Platform  string `json:"platform" bson:"platform"`
+		Hash      string `json:"hash" bson:"hash"`
+		Status    string `json:"status" bson:"status"`
+		CreatedAt int64  `json:"created_at" bson:"created_at"`
 	}
 
 	OwnerObject struct {
 		ID           primitive.ObjectID   `json:"_id" bson:"_id,omitempty"`
 		Applications []primitive.ObjectID `json:"app_ids" bson:"app_ids"`
 		User         primitive.ObjectID   `json:"user_id" bson:"user_id"`
+		Members      []MemberObject       `json:"members" bson:"members"`
+		Plan         PlanObject           `json:"plan" bson:"plan"`
+		// LicenseCount is what counts against the plan, owners created before it existed get it on their next license
+		LicenseCount *int64 `json:"license_count,omitempty" bson:"license_count,omitempty"`
+		// ActiveDevices is claimed before a new device is bound, it is recounted whenever it is found full
+		ActiveDevices *int64 `json:"active_devices,omitempty" bson:"active_devices,omitempty"`
+	}
+
+	// PlanObject caps what an owner can create, a limit of 0 is unlimited
+	PlanObject struct {
+		MaxApplications  int64 `json:"max_applications" bson:"max_applications"`
+		MaxLicenses      int64 `json:"max_licenses" bson:"max_licenses"`
+		MaxActiveDevices int64 `json:"max_active_devices" bson:"max_active_devices"`
+	}
+
+	// MemberObject is a user invited to an owner, no AppIDs means every application
+	MemberObject struct {
+		UserID  primitive.ObjectID   `json:"user_id" bson:"user_id"`
+		Role    string               `json:"role" bson:"role"`
+		AppIDs  []primitive.ObjectID `json:"app_ids" bson:"app_ids"`
+		AddedAt int64                `json:"added_at" bson:"added_at"`
+	}
+
+	InvitationObject struct {
+		ID        primitive.ObjectID   `json:"_id" bson:"_id,omitempty"`
+		OwnerID   primitive.ObjectID   `json:"owner_id" bson:"owner_id"`
+		Token     string               `json:"token" bson:"token"`
+		Role      string               `json:"role" bson:"role"`
+		AppIDs    []primitive.ObjectID `json:"app_ids" bson:"app_ids"`
+		InvitedBy primitive.ObjectID   `json:"invited_by" bson:"invited_by"`
+		ExpiresAt int64                `json:"expires_at" bson:"expires_at"`
 	}
 
 	LicenseObject struct {
-		ID             primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
-		Application    primitive.ObjectID `json:"app_id" bson:"app_id"`
-		OwnerID        primitive.ObjectID `json:"owner_id" bson:"owner_id"`
-		Key            string             `json:"key" bson:"key"`
-		Fingerprint    *string            `json:"fingerprint" bson:"fingerprint"`
-		ExpectedExpiry uint64             `json:"expected_expiry" bson:"expected_expiry"`
-		Expiry         *uint64            `json:"expiry" bson:"expiry"`
+		ID          primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+		Application primitive.ObjectID `json:"app_id" bson:"app_id"`
+		OwnerID     primitive.ObjectID `json:"owner_id" bson:"owner_id"`
+		Key         string             `json:"key" bson:"key"`
+		Hashed      bool               `json:"hashed" bson:"hashed"`
+		// Checksummed is false for keys issued before they carried a checksum segment
+		Checksummed     bool               `json:"checksummed" bson:"checksummed"`
+		Hint            string             `json:"hint" bson:"hint"`
+		Fingerprint     *string            `json:"fingerprint" bson:"fingerprint"`
+		ExpectedExpiry  uint64             `json:"expected_expiry" bson:"expected_expiry"`
+		Expiry          *uint64            `json:"expiry" bson:"expiry"`
+		Metadata        map[string]string  `json:"metadata" bson:"metadata"`
+		VisibleMetadata []string           `json:"visible_metadata" bson:"visible_metadata"`
+		Notes           string             `json:"notes" bson:"notes"`
+		Devices         []string           `json:"devices" bson:"devices"`
+		IPs             []string           `json:"ips" bson:"ips"`
+		Revoked         bool               `json:"revoked" bson:"revoked"`
+		ExpiryNotified  bool               `json:"expiry_notified" bson:"expiry_notified"`
+		Tier            string             `json:"tier" bson:"tier"`
+		CreatedBy       primitive.ObjectID `json:"created_by" bson:"created_by"`
+		ResellerID      primitive.ObjectID `json:"reseller_id" bson:"reseller_id"`
 	}
 
 	UserObject struct {
+		ID          primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+		Admin       int8               `json:"admin" bson:"admin"`
+		Role        string             `json:"role" bson:"role"`
+		Permissions []string           `json:"permissions" bson:"permissions"`
+		Username    string             `json:"username" bson:"username"`
+		Password    string             `json:"password" bson:"password"`
+		TwoFactor   TwoFactorObject    `json:"two_factor" bson:"two_factor"`
+		// Email is optional, it can only be used to reset the password once it is verified
+		Email         string `json:"email,omitempty" bson:"email,omitempty"`
+		EmailVerified bool   `json:"email_verified" bson:"email_verified"`
+		// ExpiresAt of 0 never expires, a suspended or expired account can't log in or use its tokens
+		ExpiresAt     int64  `json:"expires_at" bson:"expires_at"`
+		Suspended     bool   `json:"suspended" bson:"suspended"`
+		SuspendReason string `json:"suspend_reason" bson:"suspend_reason"`
+	}
+
+	// TwoFactorObject holds the TOTP secret encrypted and the recovery codes as keyed hashes.
+	// LastCounter is the time step of the last accepted code, so a code can't be used twice.
+	TwoFactorObject struct {
+		Enabled       bool     `json:"enabled" bson:"enabled"`
+		Secret        string   `json:"secret" bson:"secret"`
+		Pending       string   `json:"pending" bson:"pending"`
+		RecoveryCodes []string `json:"recovery_codes" bson:"recovery_codes"`
+		LastCounter   uint64   `json:"last_counter" bson:"last_counter"`
+	}
+
+	WebhookObject struct {
+		ID        primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+		OwnerID   primitive.ObjectID `json:"owner_id" bson:"owner_id"`
+		AppID     primitive.ObjectID `json:"app_id" bson:"app_id"`
+		URL       string             `json:"url" bson:"url"`
+		Secret    string             `json:"secret" bson:"secret"`
+		Events    []string           `json:"events" bson:"events"`
+		CreatedAt int64              `json:"created_at" bson:"created_at"`
+	}
+
+	DeliveryObject struct {
 		ID           primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
-		Admin        int8               `json:"admin" bson:"admin"`
-		RefreshToken string             `json:"refresh_token" bson:"refresh_token"`
-		Username     string             `json:"username" bson:"username"`
-		Password     string             `json:"password" bson:"password"`
+		WebhookID    primitive.ObjectID `json:"webhook_id" bson:"webhook_id"`
+		AppID        primitive.ObjectID `json:"app_id" bson:"app_id"`
+		Event        string             `json:"event" bson:"event"`
+		Payload      string             `json:"payload" bson:"payload"`
+		Status       string             `json:"status" bson:"status"`
+		Attempts     int                `json:"attempts" bson:"attempts"`
+		NextAttempt  int64              `json:"next_attempt" bson:"next_attempt"`
+		LastError    string             `json:"last_error" bson:"last_error"`
+		ResponseCode int                `json:"response_code" bson:"response_code"`
+		CreatedAt    int64              `json:"created_at" bson:"created_at"`
+		DeliveredAt  int64              `json:"delivered_at" bson:"delivered_at"`
+	}
+
+	VariableObject struct {
+		ID        primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+		AppID     primitive.ObjectID `json:"app_id" bson:"app_id"`
+		Name      string             `json:"name" bson:"name"`
+		Value     string             `json:"value" bson:"value"`
+		Tiers     []string           `json:"tiers" bson:"tiers"`
+		UpdatedAt int64              `json:"updated_at" bson:"updated_at"`
+	}
+
+	// AccessRuleObject blocks or exclusively allows a fingerprint or an IP range, IP values are kept in CIDR form
+	AccessRuleObject struct {
+		ID        primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+		AppID     primitive.ObjectID `json:"app_id" bson:"app_id"`
+		Kind      string             `json:"kind" bson:"kind"`
+		Value     string             `json:"value" bson:"value"`
+		Action    string             `json:"action" bson:"action"`
+		Reason    string             `json:"reason" bson:"reason"`
+		ExpiresAt int64              `json:"expires_at" bson:"expires_at"`
+		Hits      int64              `json:"hits" bson:"hits"`
+		LastHit   int64              `json:"last_hit" bson:"last_hit"`
+		CreatedAt int64              `json:"created_at" bson:"created_at"`
+	}
+
+	// RefreshSessionObject is one signed in device, Token is the keyed hash of its refresh token.
+	RefreshSessionObject struct {
+		ID        primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+		UserID    primitive.ObjectID `json:"user_id" bson:"user_id"`
+		Token     string             `json:"token" bson:"token"`
+		Device    string             `json:"device" bson:"device"`
+		IP        string             `json:"ip" bson:"ip"`
+		CreatedAt int64              `json:"created_at" bson:"created_at"`
+		LastUsed  int64              `json:"last_used" bson:"last_used"`
+		ExpiresAt int64              `json:"expires_at" bson:"expires_at"`
+	}
+
+	// AuditLogObject records an administrative action, the actor is empty for events raised by the server itself.
+	AuditLogObject struct {
+		ID        primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+		ActorID   string             `json:"actor_id" bson:"actor_id"`
+		Actor     string             `json:"actor" bson:"actor"`
+		Action    string             `json:"action" bson:"action"`
+		TargetID  string             `json:"target_id" bson:"target_id"`
+		Target    string             `json:"target" bson:"target"`
+		Details   map[string]string  `json:"details" bson:"details"`
+		IP        string             `json:"ip" bson:"ip"`
+		CreatedAt int64              `json:"created_at" bson:"created_at"`
+	}
+
+	ArchiveObject struct {
+		ID       primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+		Kind     string             `json:"kind" bson:"kind"`
+		TargetID primitive.ObjectID `json:"target_id" bson:"target_id"`
+		OwnerID  primitive.ObjectID `json:"owner_id" bson:"owner_id"`
+		ParentID primitive.ObjectID `json:"parent_id" bson:"parent_id"`
+		// Documents is only set on archives made before every document was stored on its own
+		Documents []ArchivedDocument `json:"documents,omitempty" bson:"documents,omitempty"`
+		DeletedAt int64              `json:"deleted_at" bson:"deleted_at"`
+		// Restoring is set once the plan slots of a restore are taken, so a retry doesn't take them twice
+		Restoring bool `json:"restoring" bson:"restoring"`
+	}
+
+	// ArchivedDocument keeps the raw BSON so ObjectIDs survive a restore.
+	// Each one is stored on its own so a large cascade never reaches the document size limit, Step keeps parents before children.
+	ArchivedDocument struct {
+		ID         primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+		ArchiveID  primitive.ObjectID `json:"archive_id" bson:"archive_id"`
+		Step       int                `json:"step" bson:"step"`
+		Collection string             `json:"collection" bson:"collection"`
+		Data       bson.Raw           `json:"data" bson:"data"`
+		DeletedAt  int64              `json:"deleted_at" bson:"deleted_at"`
+	}
+
+	APITokenObject struct {
+		ID        primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+		OwnerID   primitive.ObjectID `json:"owner_id" bson:"owner_id"`
+		UserID    primitive.ObjectID `json:"user_id" bson:"user_id"`
+		Name      string             `json:"name" bson:"name"`
+		Token     string             `json:"token" bson:"token"`
+		Hint      string             `json:"hint" bson:"hint"`
+		Scopes    []string           `json:"scopes" bson:"scopes"`
+		ExpiresAt int64              `json:"expires_at" bson:"expires_at"`
+		LastUsed  int64              `json:"last_used" bson:"last_used"`
+		CreatedAt int64              `json:"created_at" bson:"created_at"`
+	}
+
+	ResellerObject struct {
+		ID        primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+		OwnerID   primitive.ObjectID `json:"owner_id" bson:"owner_id"`
+		UserID    primitive.ObjectID `json:"user_id" bson:"user_id"`
+		Name      string             `json:"name" bson:"name"`
+		Quotas    []QuotaObject      `json:"quotas" bson:"quotas"`
+		Templates []TemplateObject   `json:"templates" bson:"templates"`
+		Disabled  bool               `json:"disabled" bson:"disabled"`
+		CreatedAt int64              `json:"created_at" bson:"created_at"`
+	}
+
+	// QuotaObject keeps what is left next to what was used so it can be claimed atomically
+	QuotaObject struct {
+		AppID     primitive.ObjectID `json:"app_id" bson:"app_id"`
+		Limit     int64              `json:"limit" bson:"limit"`
+		Used      int64              `json:"used" bson:"used"`
+		Remaining int64              `json:"remaining" bson:"remaining"`
+	}
+
+	// TemplateObject is a kind of license a reseller is allowed to create
+	TemplateObject struct {
+		Name   string `json:"name" bson:"name"`
+		Expiry uint64 `json:"expiry" bson:"expiry"`
+		Tier   string `json:"tier" bson:"tier"`
+		Mask   string `json:"mask" bson:"mask"`
 	}
 
 	DataTypes interface {
-		ApplicationObject | OwnerObject | LicenseObject | UserObject
+		ApplicationObject | OwnerObject | LicenseObject | UserObject | WebhookObject | DeliveryObject | VariableObject | ArchiveObject | InvitationObject | APITokenObject | ResellerObject | AccessRuleObject | AuditLogObject | RefreshSessionObject | ArchivedDocument
 	}
 )
+
+// FindMember returns the owner's membership of a user, nil if they aren't a member
+func (o *OwnerObject) FindMember(userID primitive.ObjectID) *MemberObject {
+	for i := range o.Members {
+		if o.Members[i].UserID == userID {
+			return &o.Members[i]
+		}
+	}
+
+	return nil
+}
+
+// FindBuild looks up a registered build by its SHA-256 hash
+func (a *ApplicationObject) FindBuild(hash string) *BuildObject {
+	for i := range a.Builds {
+		if a.Builds[i].Hash == hash {
+			return &a.Builds[i]
+		}
+	}
+
+	return nil
+}
+
+// DefaultPolicy is what every application got before policies existed: one device and a pinned build.
+func DefaultPolicy() *PolicyObject {
+	return &PolicyObject{
+		EnforceFingerprint: true,
+		EnforceIntegrity:   true,
+		MaxDevices:         1,
+	}
+}
+
+// GetPolicy returns the application's policy, falling back to the default for older applications
+func (a *ApplicationObject) GetPolicy() *PolicyObject {
+	if a.Policy == nil {
+		policy := DefaultPolicy()
+		policy.AllowTrustOnFirstUse = a.TrustOnFirstUse
+		return policy
+	}
+
+	return a.Policy
+}
+
+// GetRole returns the user's role, accounts created before roles existed fall back to their admin flag
+func (u *UserObject) GetRole() string {
+	if u.Role != "" {
+		return u.Role
+	}
+
+	if u.Admin == 1 {
+		return RoleAdmin
+	}
+
+	return RoleOwner
+}
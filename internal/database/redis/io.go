@@ -7,10 +7,64 @@ import (
 	"time"
 
 	types "github.com/Aran404/Goauth/internal/types"
+	"github.com/redis/go-redis/v9"
 )
 
 func (c *Connection) Set(ctx context.Context, key string, value any, expiration time.Duration) error {
-	return c.Client.Set(ctx, key, value, 0).Err()
+	return c.Client.Set(ctx, key, value, expiration).Err()
+}
+
+// SetNX sets a key only when it doesn't exist yet and reports whether it did
+func (c *Connection) SetNX(ctx context.Context, key string, value any, expiration time.Duration) (bool, error) {
+	return c.Client.SetNX(ctx, key, value, expiration).Result()
+}
+
+// Get returns types.ErrorNotFound when the key does not exist
+func (c *Connection) Get(ctx context.Context, key string) (string, error) {
+	value, err := c.Client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return "", types.ErrorNotFound
+	}
+
+	return value, err
+}
+
+// GetDel returns a value and deletes it in one step, so it can only ever be read once
+func (c *Connection) GetDel(ctx context.Context, key string) (string, error) {
+	value, err := c.Client.GetDel(ctx, key).Result()
+	if err == redis.Nil {
+		return "", types.ErrorNotFound
+	}
+
+	return value, err
+}
+
+// incrScript increments a counter and gives it an expiration when it has none, in one step on the server.
+var incrScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if redis.call("PTTL", KEYS[1]) == -1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count
+`)
+
+// Incr increments a counter, the expiration is only set when the counter has none.
+// Both happen in one script, so a counter that expires in between is recreated with its expiration.
+func (c *Connection) Incr(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	return incrScript.Run(ctx, c.Client, []string{key}, expiration.Milliseconds()).Int64()
+}
+
+// decrScript only decrements a counter that still exists, so an expired one isn't recreated without an expiration.
+var decrScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	return redis.call("DECR", KEYS[1])
+end
+return 0
+`)
+
+// Decr takes back an increment of a counter made with Incr
+func (c *Connection) Decr(ctx context.Context, key string) (int64, error) {
+	return decrScript.Run(ctx, c.Client, []string{key}).Int64()
 }
 
 func (c *Connection) HSet(ctx context.Context, key string, value any, expiration time.Duration) error {
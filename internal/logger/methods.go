@@ -33,6 +33,7 @@ const (
 	NInfo = iota
 	NError
 	NFatal
+	NWarn
 )
 
 func AppendLine(filepath string, s string, m *sync.Mutex) error {
@@ -54,6 +55,12 @@ func Error(stack string, format string, content ...any) {
 	LogMessages(NError, fmt.Sprintf(format, content...), stack)
 }
 
+// Warn is always written to the events log, for things an operator should know about that aren't failures
+func Warn(stack string, format string, content ...any) {
+	log.Warn(fmt.Sprintf(format, content...))
+	LogMessages(NWarn, fmt.Sprintf(format, content...), stack)
+}
+
 // When verbose is false, this function is a noop
 func Info(format string, content ...any) {
 	if !types.Cfg.Verbose {
@@ -86,6 +93,8 @@ func LogMessages[T constraints.Integer, S comparable](level T, message S, stackT
 		logs = fmt.Sprintf("%v [INFO] %v -> %v", GetExplicitTime(), stackTrace, message)
 	case NError:
 		logs = fmt.Sprintf("%v [ERROR] %v -> %v", GetExplicitTime(), stackTrace, message)
+	case NWarn:
+		logs = fmt.Sprintf("%v [WARN] %v -> %v", GetExplicitTime(), stackTrace, message)
 	case NFatal:
 		logs = fmt.Sprintf("%v [FATAL] %v -> %v", GetExplicitTime(), stackTrace, message)
 	}
@@ -0,0 +1,23 @@
+package mailer
+
+import (
+	"sync"
+
+	log "github.com/Aran404/Goauth/internal/logger"
+)
+
+// File appends every email to a file instead of sending it, for local testing.
+type File struct {
+	Path string
+	From string
+
+	mutex *sync.Mutex
+}
+
+func NewFile(path, from string) *File {
+	return &File{Path: path, From: from, mutex: &sync.Mutex{}}
+}
+
+func (m *File) Send(to, subject, body string) error {
+	return log.AppendLine(m.Path, string(Compose(m.From, to, subject, body)), m.mutex)
+}
@@ -0,0 +1,17 @@
+package mailer
+
+import log "github.com/Aran404/Goauth/internal/logger"
+
+// Log prints every email instead of sending it, verbose has to be on to see them.
+type Log struct {
+	From string
+}
+
+func NewLog(from string) *Log {
+	return &Log{From: from}
+}
+
+func (m *Log) Send(to, subject, body string) error {
+	log.Info("Mail to %v, Subject: %v\n%v", to, subject, body)
+	return nil
+}
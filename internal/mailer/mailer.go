@@ -0,0 +1,53 @@
+package mailer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/Aran404/Goauth/internal/logger"
+	types "github.com/Aran404/Goauth/internal/types"
+)
+
+// Mailer sends plain text emails.
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// New creates the mailer set by mail.driver in the config, an unknown driver stops the server.
+func New() Mailer {
+	cfg := types.Cfg.Mail
+	switch cfg.Driver {
+	case "smtp":
+		return NewSMTP(cfg.Host, cfg.Port, cfg.Username, cfg.Password, cfg.From)
+	case "file":
+		return NewFile(cfg.Path, cfg.From)
+	case "", "log":
+		if !types.Cfg.Verbose {
+			log.Warn(log.GetStackTrace(), "Mail driver is log but verbose is off, no email will be visible anywhere. Turn on verbose or use the smtp or file driver.")
+		}
+		return NewLog(cfg.From)
+	}
+
+	log.Fatal(log.GetStackTrace(), "Unknown mail driver: %v", cfg.Driver)
+	return nil
+}
+
+// header strips line breaks so a value can't add headers of its own
+func header(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
+
+// Compose builds the raw message that is sent over SMTP or written to a file.
+func Compose(from, to, subject, body string) []byte {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", header(from))
+	fmt.Fprintf(&msg, "To: %s\r\n", header(to))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", header(subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n"))
+	msg.WriteString("\r\n")
+	return []byte(msg.String())
+}
@@ -0,0 +1,228 @@
+package server
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	mongo "github.com/Aran404/Goauth/internal/database/mongo"
+	log "github.com/Aran404/Goauth/internal/logger"
+	types "github.com/Aran404/Goauth/internal/types"
+	"github.com/gofiber/fiber/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// NormalizeRule validates the value of a rule, a single IP becomes a /32 or /128 range.
+func NormalizeRule(kind, value string) (string, error) {
+	switch kind {
+	case mongo.RuleFingerprint:
+		return value, nil
+	case mongo.RuleIP:
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return "", types.ErrorInvalidRule
+			}
+
+			if ip.To4() != nil {
+				return ip.String() + "/32", nil
+			}
+			return ip.String() + "/128", nil
+		}
+
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return "", types.ErrorInvalidRule
+		}
+		return network.String(), nil
+	}
+
+	return "", types.ErrorInvalidRule
+}
+
+// RuleMatches reports whether a rule applies to a client, an IP rule never matches a missing IP.
+func RuleMatches(rule *mongo.AccessRuleObject, ip net.IP, fingerprint string) bool {
+	switch rule.Kind {
+	case mongo.RuleFingerprint:
+		return fingerprint != "" && rule.Value == fingerprint
+	case mongo.RuleIP:
+		_, network, err := net.ParseCIDR(rule.Value)
+		return err == nil && ip != nil && network.Contains(ip)
+	}
+
+	return false
+}
+
+// checkAccess runs the application's access rules against a client, an empty fingerprint is not checked.
+// Deny rules always win. Once an application has allow rules of a kind, anything they don't match is refused.
+func (s *Server) checkAccess(appID primitive.ObjectID, ip, fingerprint, stage string) error {
+	query := bson.M{"app_id": appID, "$or": bson.A{bson.M{"expires_at": 0}, bson.M{"expires_at": bson.M{"$gt": time.Now().Unix()}}}}
+	unparsed, err := s.db.Find(s.dbCtx, mongo.AccessRules, query)
+	if err != nil {
+		return err
+	}
+
+	var rules []mongo.AccessRuleObject
+	if err := mongo.ReadAllInto[mongo.AccessRuleObject](unparsed, &rules); err != nil {
+		return err
+	}
+
+	if rule, err := EvaluateRules(rules, ip, fingerprint); err != nil {
+		s.blocked(appID, rule, ip, fingerprint, stage)
+		return err
+	}
+
+	return nil
+}
+
+// EvaluateRules runs access rules against a client and returns the deny rule that refused it, nil when no allow rule matched.
+func EvaluateRules(rules []mongo.AccessRuleObject, ip, fingerprint string) (*mongo.AccessRuleObject, error) {
+	parsedIP := net.ParseIP(ip)
+	values := map[string]string{mongo.RuleIP: ip, mongo.RuleFingerprint: fingerprint}
+	allowed := map[string]bool{}
+	restricted := map[string]bool{}
+
+	for i := range rules {
+		rule := &rules[i]
+		if values[rule.Kind] == "" {
+			continue
+		}
+
+		matches := RuleMatches(rule, parsedIP, fingerprint)
+		switch rule.Action {
+		case mongo.RuleDeny:
+			if matches {
+				return rule, types.ErrorAccessDenied
+			}
+		case mongo.RuleAllow:
+			restricted[rule.Kind] = true
+			if matches {
+				allowed[rule.Kind] = true
+			}
+		}
+	}
+
+	for kind := range restricted {
+		if !allowed[kind] {
+			return nil, types.ErrorAccessDenied
+		}
+	}
+
+	return nil, nil
+}
+
+// blocked logs a refused client and counts the hit against the rule that refused it, if there was one.
+func (s *Server) blocked(appID primitive.ObjectID, rule *mongo.AccessRuleObject, ip, fingerprint, stage string) {
+	reason := "not on the allow list"
+	if rule != nil {
+		reason = "denied by rule " + rule.ID.Hex()
+		if rule.Reason != "" {
+			reason += " (" + rule.Reason + ")"
+		}
+
+		update := bson.M{"$inc": bson.M{"hits": 1}, "$set": bson.M{"last_hit": time.Now().Unix()}}
+		if _, err := s.db.Modify(s.dbCtx, mongo.AccessRules, bson.M{"_id": rule.ID}, update); err != nil {
+			log.Error(log.GetStackTrace(), "Could not count access rule hit, Error: %v", err.Error())
+		}
+	}
+
+	log.Warn(log.GetStackTrace(), "Blocked %v for application %v, IP: %v, Fingerprint: %v, Reason: %v", stage, appID.Hex(), ip, fingerprint, reason)
+}
+
+// SetAccessRule adds a rule to an application, setting the same kind and value again replaces it
+func (s *Server) SetAccessRule(c fiber.Ctx) error {
+	var msg AccessRuleMsg
+	session, err := s.parseAppBody(c, &msg, "Reason")
+	if err != nil {
+		return err
+	}
+
+	if msg.Action != mongo.RuleDeny && msg.Action != mongo.RuleAllow {
+		return types.ErrorInvalidRule
+	}
+
+	if msg.ExpiresAt != 0 && msg.ExpiresAt <= time.Now().Unix() {
+		return types.ErrorInvalidRule
+	}
+
+	value, err := NormalizeRule(msg.Kind, msg.Value)
+	if err != nil {
+		return err
+	}
+
+	_, app, err := s.authorizeApp(c, msg.OwnerID, msg.AppID)
+	if err != nil {
+		return err
+	}
+
+	// One upsert, so concurrent sets of the same rule can't both create it
+	query := bson.M{"app_id": app.ID, "kind": msg.Kind, "value": value}
+	update := bson.M{
+		"$set":         bson.M{"action": msg.Action, "reason": msg.Reason, "expires_at": msg.ExpiresAt},
+		"$setOnInsert": bson.M{"hits": 0, "last_hit": 0, "created_at": time.Now().Unix()},
+	}
+	if err := s.db.Upsert(s.dbCtx, mongo.AccessRules, query, update); err != nil {
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "value": value, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// ListAccessRules returns the rules of an application, expired ones included
+func (s *Server) ListAccessRules(c fiber.Ctx) error {
+	var msg AppMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	_, app, err := s.authorizeApp(c, msg.OwnerID, msg.AppID)
+	if err != nil {
+		return err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	unparsed, err := s.db.Find(s.dbCtx, mongo.AccessRules, bson.M{"app_id": app.ID}, opts)
+	if err != nil {
+		return err
+	}
+
+	rules := []mongo.AccessRuleObject{}
+	if err := mongo.ReadAllInto[mongo.AccessRuleObject](unparsed, &rules); err != nil {
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "rules": rules, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+func (s *Server) DeleteAccessRule(c fiber.Ctx) error {
+	var msg AccessRuleIDMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	_, app, err := s.authorizeApp(c, msg.OwnerID, msg.AppID)
+	if err != nil {
+		return err
+	}
+
+	ruleID, err := primitive.ObjectIDFromHex(msg.RuleID)
+	if err != nil {
+		return types.ErrorInvalidRule
+	}
+
+	if err := s.db.Delete(s.dbCtx, mongo.AccessRules, bson.M{"_id": ruleID, "app_id": app.ID}); err != nil {
+		if err == types.ErrorNoMatches {
+			return types.ErrorInvalidRule
+		}
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
@@ -5,7 +5,6 @@ import (
 	"os"
 	"time"
 
-	crypto "github.com/Aran404/Goauth/internal/crypto"
 	mongo "github.com/Aran404/Goauth/internal/database/mongo"
 	types "github.com/Aran404/Goauth/internal/types"
 	utils "github.com/Aran404/Goauth/internal/utils"
@@ -14,53 +13,60 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func (s *Server) registerBody(body []byte) (*UserMsg, int8, error) {
-	var (
-		data    *UserMsg
-		isAdmin bool
-	)
-
+func (s *Server) registerBody(body []byte) (*UserMsg, string, error) {
+	var data *UserMsg
 	if err := json.Unmarshal(body, &data); err != nil {
-		return nil, -1, types.ErrorInvalidJSON
+		return nil, "", types.ErrorInvalidJSON
 	}
 
 	if utils.CheckEmptyFields(data, "APIKey") {
-		return nil, -1, types.ErrorEmptyFields
+		return nil, "", types.ErrorEmptyFields
 	}
 
 	// Only owners can register admins
-	realApiKey := os.Getenv("API_KEY")
-	isAdmin = data.APIKey == realApiKey
+	role := mongo.RoleOwner
+	if realApiKey := os.Getenv("API_KEY"); realApiKey != "" && data.APIKey == realApiKey {
+		role = mongo.RoleAdmin
+	}
 
-	// Verify Password
-	if !utils.CheckPassword(data.Password) {
-		return nil, -1, types.ErrorInsecurePassword
+	if err := utils.CheckPassword(data.Password, data.Username, &types.Cfg.PasswordPolicy); err != nil {
+		return nil, "", err
 	}
 
-	if len(data.Username) < 3 || len(data.Username) > 20 {
-		return nil, -1, types.ErrorIncorrectLength
+	if err := s.checkUsername(data.Username); err != nil {
+		return nil, "", err
+	}
+
+	return data, role, nil
+}
+
+// checkUsername makes sure a username is valid and not taken.
+func (s *Server) checkUsername(username string) error {
+	if err := utils.CheckUsername(username, &types.Cfg.UsernamePolicy); err != nil {
+		return err
 	}
 
 	// Make sure account doesn't already exist
-	exists, err := s.db.Exists(s.dbCtx, mongo.Users, bson.M{"username": data.Username})
+	exists, err := s.db.Exists(s.dbCtx, mongo.Users, bson.M{"username": username})
 	if err != nil {
-		return nil, -1, err
+		return err
 	}
 
 	if exists {
-		return nil, -1, types.ErrorAccountExists
+		return types.ErrorAccountExists
 	}
 
-	return data, utils.Btoi(isAdmin), nil
+	return nil
 }
 
-func (s *Server) finalizeRegister(c fiber.Ctx, admin int8, msg *UserMsg, session *Session) error {
+func (s *Server) finalizeRegister(c fiber.Ctx, role string, msg *UserMsg, session *Session) error {
 	dump := &mongo.UserObject{
-		Username: msg.Username,
-		Admin:    admin,
+		Username:    msg.Username,
+		Role:        role,
+		Permissions: []string{},
 	}
 
-	hashed, err := crypto.HashPassword(msg.Password)
+	hashed, err := s.passwords.Hash(msg.Password)
 	if err != nil {
 		return err
 	}
@@ -75,7 +81,8 @@ func (s *Server) finalizeRegister(c fiber.Ctx, admin int8, msg *UserMsg, session
 	return s.EncryptJson(c, returnDump, session)
 }
 
-func (s *Server) parseLoginBody(body []byte) (*mongo.UserObject, error) {
+// parseLoginBody checks the credentials, an unknown username and a wrong password give the same error.
+func (s *Server) parseLoginBody(body []byte, ip string) (*mongo.UserObject, error) {
 	var data *UserMsg
 	if err := json.Unmarshal(body, &data); err != nil {
 		return nil, types.ErrorInvalidJSON
@@ -85,20 +92,51 @@ func (s *Server) parseLoginBody(body []byte) (*mongo.UserObject, error) {
 		return nil, types.ErrorEmptyFields
 	}
 
-	dump, err := s.db.Filter(s.dbCtx, mongo.Users, bson.M{"username": data.Username}, false, types.ErrorUserNotFound)
+	attempt, err := s.beginAttempt(data.Username, ip)
 	if err != nil {
 		return nil, err
 	}
 
+	dump, err := s.db.Filter(s.dbCtx, mongo.Users, bson.M{"username": data.Username}, false, types.ErrorUserNotFound)
+	if err != nil {
+		if err != types.ErrorUserNotFound {
+			attempt.release()
+			return nil, err
+		}
+
+		if _, err := s.passwords.Check(data.Password, s.dummyHash); err != nil {
+			attempt.release()
+			return nil, err
+		}
+		attempt.failed()
+		return nil, types.ErrorInvalidCredentials
+	}
+
 	var user mongo.UserObject
 	if err := mongo.ReadInto[mongo.UserObject](dump, &user); err != nil {
+		attempt.release()
 		return nil, err
 	}
 
-	if !crypto.CheckPasswordHash(data.Password, user.Password) {
-		return nil, types.ErrorIncorrectPassword
+	ok, rehash, err := s.passwords.Verify(data.Password, user.Password)
+	if err != nil {
+		attempt.release()
+		return nil, err
 	}
 
+	if !ok {
+		attempt.failed()
+		return nil, types.ErrorInvalidCredentials
+	}
+
+	attempt.release()
+	if err := accountStatus(&user); err != nil {
+		return nil, err
+	}
+
+	if rehash {
+		s.rehashPassword(&user, data.Password)
+	}
 	return &user, nil
 }
 
@@ -109,18 +147,26 @@ func (s *Server) Login(c fiber.Ctx) error {
 		return err
 	}
 
-	data, err := s.parseLoginBody(body)
+	data, err := s.parseLoginBody(body, c.IP())
 	if err != nil {
 		return err
 	}
 
-	resp, err := s.GenerateKeyPair(data.Username, data.Admin)
-	if err != nil {
-		return err
+	// Tokens are only issued once the second step is passed
+	if data.TwoFactor.Enabled {
+		ticket, err := s.loginTicket(data)
+		if err != nil {
+			return err
+		}
+
+		// Failures are only cleared once the code is accepted too
+		returnDump := fiber.Map{"success": true, "two_factor": true, "ticket": ticket, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+		return s.EncryptJson(c, returnDump, session)
 	}
 
-	updatePayload := bson.M{"refresh_token": (*resp)["refresh_token"].(string)}
-	if err := s.db.Update(s.dbCtx, mongo.Users, bson.M{"username": data.Username}, updatePayload); err != nil {
+	s.loginSucceeded(data.Username)
+	resp, err := s.startSession(c, data)
+	if err != nil {
 		return err
 	}
 
@@ -134,25 +180,186 @@ func (s *Server) Register(c fiber.Ctx) error {
 		return err
 	}
 
-	msg, admin, err := s.registerBody(body)
+	msg, role, err := s.registerBody(body)
 	if err != nil {
 		return err
 	}
 
-	return s.finalizeRegister(c, admin, msg, session)
+	return s.finalizeRegister(c, role, msg, session)
 }
 
-// Logout will delete the refresh token from the database
+// Logout will delete the session of the access token, which stops both of its tokens
 func (s *Server) Logout(c fiber.Ctx) error {
-	refreshToken := c.Cookies("refresh_token")
-	if refreshToken == "" {
-		return types.ErrorNoRefreshToken
+	fields, err := s.parseJWTFields(c)
+	if err != nil {
+		return err
+	}
+
+	sessionID, err := primitive.ObjectIDFromHex(fields.SessionID)
+	if err != nil {
+		return types.ErrorSessionNotFound
+	}
+
+	userID, err := primitive.ObjectIDFromHex(fields.UserID)
+	if err != nil {
+		return fiber.ErrUnauthorized
+	}
+
+	if err := s.db.Delete(s.dbCtx, mongo.RefreshSessions, bson.M{"_id": sessionID, "user_id": userID}); err != nil && err != types.ErrorNoMatches {
+		return err
+	}
+
+	return nil
+}
+
+// currentUser loads the user the request is authenticated as.
+func (s *Server) currentUser(c fiber.Ctx) (*mongo.UserObject, error) {
+	fields, err := s.parseJWTFields(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.getUser(fields.UserID)
+}
+
+// ChangePassword requires the current password, every other session is signed out and new tokens are returned
+func (s *Server) ChangePassword(c fiber.Ctx) error {
+	var msg ChangePasswordMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkPasswordAttempt(user, msg.Password, c.IP()); err != nil {
+		return err
+	}
+
+	if err := utils.CheckPassword(msg.NewPassword, user.Username, &types.Cfg.PasswordPolicy); err != nil {
+		return err
+	}
+
+	hashed, err := s.passwords.Hash(msg.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.Update(s.dbCtx, mongo.Users, bson.M{"_id": user.ID}, bson.M{"password": hashed}); err != nil {
+		return err
+	}
+
+	resp, err := s.replaceSessions(c, user)
+	if err != nil {
+		return err
 	}
 
-	return s.db.Update(s.dbCtx, mongo.Users, bson.M{"refresh_token": refreshToken}, bson.M{"refresh_token": ""})
+	return s.EncryptJson(c, *resp, session)
+}
+
+// ChangeUsername renames the account and returns new tokens, owners and members refer to users by ID so nothing else changes
+func (s *Server) ChangeUsername(c fiber.Ctx) error {
+	var msg ChangeUsernameMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkUsername(msg.Username); err != nil {
+		return err
+	}
+
+	if err := s.db.Update(s.dbCtx, mongo.Users, bson.M{"_id": user.ID}, bson.M{"username": msg.Username}); err != nil {
+		return err
+	}
+	user.Username = msg.Username
+
+	// Access tokens carry the username, the current session is swapped for one with new tokens
+	fields, err := s.parseJWTFields(c)
+	if err != nil {
+		return err
+	}
+
+	if sessionID, err := primitive.ObjectIDFromHex(fields.SessionID); err == nil {
+		if err := s.db.Delete(s.dbCtx, mongo.RefreshSessions, bson.M{"_id": sessionID, "user_id": user.ID}); err != nil && err != types.ErrorNoMatches {
+			return err
+		}
+	}
+
+	resp, err := s.startSession(c, user)
+	if err != nil {
+		return err
+	}
+
+	return s.EncryptJson(c, *resp, session)
 }
 
-// ! Probably won't do these anytime soon unless the project picks up some traction
-// TODO: Delete account
-// TODO: Change password
-// TODO: Change username
+// DeleteAccount removes the signed in user after checking their password.
+// Owners the user is the primary user of are removed with them as long as they have no applications left,
+// otherwise the delete is refused so no customer's licenses disappear with an account.
+func (s *Server) DeleteAccount(c fiber.Ctx) error {
+	var msg PasswordMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkPasswordAttempt(user, msg.Password, c.IP()); err != nil {
+		return err
+	}
+
+	unparsed, err := s.db.Find(s.dbCtx, mongo.Owners, bson.M{"user_id": user.ID})
+	if err != nil {
+		return err
+	}
+
+	var owners []mongo.OwnerObject
+	if err := mongo.ReadAllInto[mongo.OwnerObject](unparsed, &owners); err != nil {
+		return err
+	}
+
+	for _, v := range owners {
+		if len(v.Applications) > 0 {
+			return types.ErrorAccountInUse
+		}
+	}
+
+	for i := range owners {
+		record := &mongo.ArchiveObject{Kind: mongo.ArchivedOwner, TargetID: owners[i].ID, OwnerID: owners[i].ID}
+		if err := s.archive(record, ownerCascade(&owners[i]), true); err != nil {
+			return err
+		}
+	}
+
+	if _, err := s.db.ModifyMany(s.dbCtx, mongo.Owners, bson.M{"members.user_id": user.ID}, bson.M{"$pull": bson.M{"members": bson.M{"user_id": user.ID}}}); err != nil {
+		return err
+	}
+
+	// Licenses keep the IDs of who created them, only access is removed
+	for _, collection := range []string{mongo.APITokens, mongo.Resellers, mongo.RefreshSessions} {
+		if err := s.db.Delete(s.dbCtx, collection, bson.M{"user_id": user.ID}); err != nil && err != types.ErrorNoMatches {
+			return err
+		}
+	}
+
+	// The user goes last so a failed delete can simply be retried
+	if err := s.db.Delete(s.dbCtx, mongo.Users, bson.M{"_id": user.ID}); err != nil {
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
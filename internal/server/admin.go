@@ -2,38 +2,34 @@ package server
 
 import (
 	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	mongo "github.com/Aran404/Goauth/internal/database/mongo"
 	types "github.com/Aran404/Goauth/internal/types"
+	utils "github.com/Aran404/Goauth/internal/utils"
 	"github.com/gofiber/fiber/v3"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// Admin only route
+// Requires the owner:create permission, which only admins have by default
 func (s *Server) NewOwner(c fiber.Ctx) error {
 	session, body, err := s.ParseBody(c)
 	if err != nil {
 		return err
 	}
 
-	msg, err := s.parseJWTFields(c)
-	if err != nil {
-		return err
-	}
-
-	if time.Now().Unix() > int64(msg.Exp) || msg.Admin != 1 {
-		return c.SendStatus(fiber.StatusUnauthorized)
-	}
-
 	userID, err := s.parseNewOwnerBody(body)
 	if err != nil {
 		return err
 	}
 
 	// ID will be auto-assigned
-	payload := &mongo.OwnerObject{Applications: []primitive.ObjectID{}, User: *userID}
+	payload := &mongo.OwnerObject{Applications: []primitive.ObjectID{}, User: *userID, LicenseCount: new(int64)}
 	item, err := s.db.CreateAndReturn(s.dbCtx, mongo.Owners, payload)
 	if err != nil {
 		return err
@@ -76,7 +72,316 @@ func (s *Server) parseNewOwnerBody(body []byte) (*primitive.ObjectID, error) {
 	return &proper, nil
 }
 
-// ! Probably won't do these anytime soon unless the project picks some traction
-// TODO: Delete owner
-// TODO: Delete Specific Application
-// TODO: Expiry On Account
+// SetUserRole changes the role of a user and replaces the permissions granted on top of it.
+// The change applies to the user's next request, their access token is checked against the account every time.
+func (s *Server) SetUserRole(c fiber.Ctx) error {
+	var msg RoleMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := rolePermissions[msg.Role]; !ok {
+		return types.ErrorInvalidRole
+	}
+
+	for _, v := range msg.Permissions {
+		if !utils.ArrayContains(permissions, v) {
+			return types.ErrorInvalidRole
+		}
+	}
+
+	if msg.Permissions == nil {
+		msg.Permissions = []string{}
+	}
+
+	user, err := s.getUser(msg.UserID)
+	if err != nil {
+		if err == types.ErrorUserNotFound {
+			return types.ErrorInvalidUserID
+		}
+		return err
+	}
+
+	if err := s.notSelf(c, user); err != nil {
+		return err
+	}
+
+	if err := s.db.Update(s.dbCtx, mongo.Users, bson.M{"_id": user.ID}, bson.M{"role": msg.Role, "permissions": msg.Permissions}); err != nil {
+		return err
+	}
+
+	s.audit(c, mongo.AuditUserRole, user, map[string]string{
+		"from":        user.GetRole(),
+		"to":          msg.Role,
+		"permissions": strings.Join(msg.Permissions, ","),
+	})
+
+	returnDump := fiber.Map{"success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// notSelf stops an admin from changing their own role or status, so the last admin can't lock everyone out.
+func (s *Server) notSelf(c fiber.Ctx, user *mongo.UserObject) error {
+	fields, err := s.parseJWTFields(c)
+	if err != nil {
+		return err
+	}
+
+	if fields.UserID == user.ID.Hex() {
+		return types.WithMessage(types.ErrorInvalidUserID, "You can't change your own account here.")
+	}
+
+	return nil
+}
+
+// userView is what admins see of a user, the password, tokens and two-factor secrets are left out.
+func userView(u *mongo.UserObject) fiber.Map {
+	view := fiber.Map{
+		"id":             u.ID.Hex(),
+		"username":       u.Username,
+		"role":           u.GetRole(),
+		"permissions":    u.Permissions,
+		"two_factor":     u.TwoFactor.Enabled,
+		"email_verified": u.EmailVerified,
+		"suspended":      u.Suspended,
+		"expires_at":     u.ExpiresAt,
+		"created_at":     u.ID.Timestamp().Unix(),
+	}
+
+	if u.Email != "" {
+		view["email"] = u.Email
+	}
+
+	if u.SuspendReason != "" {
+		view["suspend_reason"] = u.SuspendReason
+	}
+
+	return view
+}
+
+// roleQuery matches the users of a role the way GetRole reads it, accounts from before roles have an admin flag instead.
+func roleQuery(role string) bson.M {
+	legacy := bson.M{"role": bson.M{"$in": bson.A{nil, ""}}}
+	switch role {
+	case mongo.RoleAdmin:
+		legacy["admin"] = 1
+	case mongo.RoleOwner:
+		legacy["admin"] = bson.M{"$ne": 1}
+	default:
+		return bson.M{"role": role}
+	}
+
+	return bson.M{"$or": bson.A{bson.M{"role": role}, legacy}}
+}
+
+// ListUsers pages through every account, query matches part of the username or email
+func (s *Server) ListUsers(c fiber.Ctx) error {
+	var msg ListUsersMsg
+	session, err := s.parseAppBody(c, &msg, "Query", "Role")
+	if err != nil {
+		return err
+	}
+
+	query := bson.M{}
+	if msg.Query != "" {
+		pattern := bson.M{"$regex": regexp.QuoteMeta(msg.Query), "$options": "i"}
+		query["$or"] = bson.A{bson.M{"username": pattern}, bson.M{"email": pattern}}
+	}
+
+	if msg.Role != "" {
+		if _, ok := rolePermissions[msg.Role]; !ok {
+			return types.ErrorInvalidRole
+		}
+		query["$and"] = bson.A{roleQuery(msg.Role)}
+	}
+
+	if msg.Suspended != nil {
+		query["suspended"] = bson.M{"$ne": true}
+		if *msg.Suspended {
+			query["suspended"] = true
+		}
+	}
+
+	if msg.Page < 1 {
+		msg.Page = 1
+	}
+
+	if msg.Limit < 1 || msg.Limit > maxPageSize {
+		msg.Limit = maxPageSize
+	}
+
+	total, err := s.db.Count(s.dbCtx, mongo.Users, query)
+	if err != nil {
+		return err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetSkip((msg.Page - 1) * msg.Limit).
+		SetLimit(msg.Limit)
+
+	unparsed, err := s.db.Find(s.dbCtx, mongo.Users, query, opts)
+	if err != nil {
+		return err
+	}
+
+	var users []mongo.UserObject
+	if err := mongo.ReadAllInto[mongo.UserObject](unparsed, &users); err != nil {
+		return err
+	}
+
+	views := []fiber.Map{}
+	for i := range users {
+		views = append(views, userView(&users[i]))
+	}
+
+	returnDump := fiber.Map{
+		"success": true,
+		"users":   views,
+		"total":   total,
+		"page":    msg.Page,
+		"limit":   msg.Limit,
+		"context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext),
+	}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// ForceLogout signs a user out of every session, their access tokens stop working right away
+func (s *Server) ForceLogout(c fiber.Ctx) error {
+	var msg UserIDMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.getUser(msg.UserID)
+	if err != nil {
+		if err == types.ErrorUserNotFound {
+			return types.ErrorInvalidUserID
+		}
+		return err
+	}
+
+	if err := s.revokeSessions(user.ID, ""); err != nil {
+		return err
+	}
+
+	s.audit(c, mongo.AuditUserLogout, user, nil)
+
+	returnDump := fiber.Map{"success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// accountStatus refuses suspended and expired accounts, it is checked on login, refresh and every restricted request.
+func accountStatus(user *mongo.UserObject) error {
+	if user.Suspended {
+		return types.WithMessage(types.ErrorAccountSuspended, user.SuspendReason)
+	}
+
+	if user.ExpiresAt != 0 && time.Now().Unix() > user.ExpiresAt {
+		return types.ErrorAccountExpired
+	}
+
+	return nil
+}
+
+// checkAccount loads the user behind an access token, so a suspension or a revoked session applies before the token expires.
+func (s *Server) checkAccount(fields *UserJWT) (*mongo.UserObject, error) {
+	user, err := s.getUser(fields.UserID)
+	if err != nil {
+		if err == types.ErrorUserNotFound || err == types.ErrorInvalidUserID {
+			return nil, fiber.ErrUnauthorized
+		}
+		return nil, err
+	}
+
+	if err := s.checkSession(user, fields.SessionID); err != nil {
+		return nil, err
+	}
+
+	if err := accountStatus(user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// freezeApps freezes or unfreezes every application of the owners the user is the primary user of.
+func (s *Server) freezeApps(userID primitive.ObjectID, frozen bool) error {
+	unparsed, err := s.db.Find(s.dbCtx, mongo.Owners, bson.M{"user_id": userID})
+	if err != nil {
+		return err
+	}
+
+	var owners []mongo.OwnerObject
+	if err := mongo.ReadAllInto[mongo.OwnerObject](unparsed, &owners); err != nil {
+		return err
+	}
+
+	ownerIDs := []primitive.ObjectID{}
+	for _, v := range owners {
+		ownerIDs = append(ownerIDs, v.ID)
+	}
+
+	if len(ownerIDs) == 0 {
+		return nil
+	}
+
+	_, err = s.db.ModifyMany(s.dbCtx, mongo.Applications, bson.M{"owner_id": bson.M{"$in": ownerIDs}}, bson.M{"$set": bson.M{"frozen": frozen}})
+	return err
+}
+
+// SetUserStatus suspends or reinstates an account and sets when it expires, an expiry of 0 never expires.
+// Suspending signs the account out, and freezes its owners' applications when security.suspend_freeze_apps is set.
+func (s *Server) SetUserStatus(c fiber.Ctx) error {
+	var msg UserStatusMsg
+	session, err := s.parseAppBody(c, &msg, "Reason")
+	if err != nil {
+		return err
+	}
+
+	if msg.ExpiresAt < 0 {
+		return types.ErrorInvalidUserID
+	}
+
+	user, err := s.getUser(msg.UserID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.notSelf(c, user); err != nil {
+		return err
+	}
+
+	update := bson.M{"suspended": msg.Suspended, "suspend_reason": msg.Reason, "expires_at": msg.ExpiresAt}
+	if !msg.Suspended {
+		update["suspend_reason"] = ""
+	}
+
+	if err := s.db.Update(s.dbCtx, mongo.Users, bson.M{"_id": user.ID}, update); err != nil {
+		return err
+	}
+
+	if msg.Suspended {
+		if err := s.revokeSessions(user.ID, ""); err != nil {
+			return err
+		}
+	}
+
+	// Reinstating always unfreezes, in case the setting was turned off while the account was suspended
+	if msg.Suspended != user.Suspended && (types.Cfg.Security.SuspendFreezeApps || !msg.Suspended) {
+		if err := s.freezeApps(user.ID, msg.Suspended); err != nil {
+			return err
+		}
+	}
+
+	s.audit(c, mongo.AuditUserStatus, user, map[string]string{
+		"suspended":  strconv.FormatBool(msg.Suspended),
+		"reason":     msg.Reason,
+		"expires_at": strconv.FormatInt(msg.ExpiresAt, 10),
+	})
+
+	returnDump := fiber.Map{"success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
@@ -0,0 +1,208 @@
+package server
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	mongo "github.com/Aran404/Goauth/internal/database/mongo"
+	types "github.com/Aran404/Goauth/internal/types"
+	utils "github.com/Aran404/Goauth/internal/utils"
+	"github.com/gofiber/fiber/v3"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var (
+	buildStatuses = []string{mongo.BuildActive, mongo.BuildDeprecated, mongo.BuildBlocked}
+	appStatuses   = []string{mongo.AppActive, mongo.AppDisabled, mongo.AppMaintenance}
+)
+
+func (s *Server) parseAppBody(c fiber.Ctx, msg any, exceptions ...string) (*Session, error) {
+	session, body, err := s.ParseBody(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(body, msg); err != nil {
+		return nil, types.ErrorInvalidJSON
+	}
+
+	if utils.CheckEmptyFields(msg, exceptions...) {
+		return nil, types.ErrorEmptyFields
+	}
+
+	return session, nil
+}
+
+// AddBuild registers a version or platform build that clients are allowed to run
+func (s *Server) AddBuild(c fiber.Ctx) error {
+	var msg BuildMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	hash := strings.ToLower(msg.Hash)
+	if raw, err := hex.DecodeString(hash); err != nil || len(raw) != 32 {
+		return types.ErrorInvalidHash
+	}
+
+	_, app, err := s.authorizeApp(c, msg.OwnerID, msg.AppID)
+	if err != nil {
+		return err
+	}
+
+	build := mongo.BuildObject{
+		Version:   msg.Version,
+		Platform:  msg.Platform,
+		Hash:      hash,
+		Status:    mongo.BuildActive,
+		CreatedAt: time.Now().Unix(),
+	}
+
+	// The hash filter keeps two concurrent requests from registering the same build
+	query := bson.M{"_id": app.ID, "builds.hash": bson.M{"$ne": hash}}
+	matched, err := s.db.Modify(s.dbCtx, mongo.Applications, query, bson.M{"$push": bson.M{"builds": build}})
+	if err != nil {
+		return err
+	}
+
+	if matched == 0 {
+		return types.ErrorBuildExists
+	}
+
+	returnDump := fiber.Map{"success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// UpdateBuild deprecates, blocks or reactivates a registered build
+func (s *Server) UpdateBuild(c fiber.Ctx) error {
+	var msg BuildStatusMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	if !utils.ArrayContains(buildStatuses, msg.Status) {
+		return types.ErrorInvalidStatus
+	}
+
+	_, app, err := s.authorizeApp(c, msg.OwnerID, msg.AppID)
+	if err != nil {
+		return err
+	}
+
+	hash := strings.ToLower(msg.Hash)
+	query := bson.M{"_id": app.ID, "builds.hash": hash}
+	matched, err := s.db.Modify(s.dbCtx, mongo.Applications, query, bson.M{"$set": bson.M{"builds.$.status": msg.Status}})
+	if err != nil {
+		return err
+	}
+
+	if matched == 0 {
+		return types.ErrorInvalidBuild
+	}
+
+	returnDump := fiber.Map{"success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// ListBuilds returns every build registered for an application
+func (s *Server) ListBuilds(c fiber.Ctx) error {
+	var msg AppMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	_, app, err := s.authorizeApp(c, msg.OwnerID, msg.AppID)
+	if err != nil {
+		return err
+	}
+
+	builds := app.Builds
+	if builds == nil {
+		builds = []mongo.BuildObject{}
+	}
+
+	returnDump := fiber.Map{
+		"success":            true,
+		"builds":             builds,
+		"trust_on_first_use": app.GetPolicy().AllowTrustOnFirstUse,
+		"context":            time.Now().Unix() + int64(types.Cfg.Security.AllowedContext),
+	}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// SetApplicationStatus disables an application or puts it in maintenance with a message for end users
+func (s *Server) SetApplicationStatus(c fiber.Ctx) error {
+	var msg AppStatusMsg
+	session, err := s.parseAppBody(c, &msg, "Message")
+	if err != nil {
+		return err
+	}
+
+	if !utils.ArrayContains(appStatuses, msg.Status) {
+		return types.ErrorInvalidStatus
+	}
+
+	_, app, err := s.authorizeApp(c, msg.OwnerID, msg.AppID)
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{"status": msg.Status, "status_message": msg.Message}
+	if err := s.db.Update(s.dbCtx, mongo.Applications, bson.M{"_id": app.ID}, update); err != nil {
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// GetPolicy returns the validation policy of an application
+func (s *Server) GetPolicy(c fiber.Ctx) error {
+	var msg AppMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	_, app, err := s.authorizeApp(c, msg.OwnerID, msg.AppID)
+	if err != nil {
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "policy": app.GetPolicy(), "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// UpdatePolicy replaces the validation policy of an application
+func (s *Server) UpdatePolicy(c fiber.Ctx) error {
+	var msg PolicyMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	if msg.Policy == nil {
+		return types.ErrorEmptyFields
+	}
+
+	if msg.Policy.MaxDevices < 0 || msg.Policy.MaxIPs < 0 {
+		return types.ErrorInvalidPolicy
+	}
+
+	_, app, err := s.authorizeApp(c, msg.OwnerID, msg.AppID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.Update(s.dbCtx, mongo.Applications, bson.M{"_id": app.ID}, bson.M{"policy": msg.Policy}); err != nil {
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
@@ -0,0 +1,486 @@
+package server
+
+import (
+	"time"
+
+	mongo "github.com/Aran404/Goauth/internal/database/mongo"
+	log "github.com/Aran404/Goauth/internal/logger"
+	types "github.com/Aran404/Goauth/internal/types"
+	"github.com/gofiber/fiber/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// cascade is one collection touched by a delete, parents must come before their children.
+type cascade struct {
+	collection string
+	query      bson.M
+}
+
+// appCascade is everything that belongs to the given applications.
+func appCascade(appIDs []primitive.ObjectID) []cascade {
+	ids := bson.M{"$in": appIDs}
+	return []cascade{
+		{mongo.Applications, bson.M{"_id": ids}},
+		{mongo.Licenses, bson.M{"app_id": ids}},
+		{mongo.Variables, bson.M{"app_id": ids}},
+		{mongo.AccessRules, bson.M{"app_id": ids}},
+		{mongo.Webhooks, bson.M{"app_id": ids}},
+		{mongo.WebhookDeliveries, bson.M{"app_id": ids}},
+	}
+}
+
+// ownerCascade is everything that belongs to an owner.
+func ownerCascade(owner *mongo.OwnerObject) []cascade {
+	ownerID := bson.M{"owner_id": owner.ID}
+	steps := []cascade{
+		{mongo.Owners, bson.M{"_id": owner.ID}},
+		{mongo.Invitations, ownerID},
+		{mongo.APITokens, ownerID},
+		{mongo.Resellers, ownerID},
+	}
+
+	return append(steps, appCascade(owner.Applications)...)
+}
+
+// archive removes every document matched by the cascade, keeping a copy in the archive unless it is permanent.
+// Documents are deleted by the IDs that were archived, so nothing created in the meantime is lost without a copy.
+func (s *Server) archive(record *mongo.ArchiveObject, steps []cascade, permanent bool) error {
+	if !permanent {
+		record.DeletedAt = time.Now().Unix()
+		item, err := s.db.CreateAndReturn(s.dbCtx, mongo.Archive, record)
+		if err != nil {
+			return err
+		}
+
+		id, ok := item.InsertedID.(primitive.ObjectID)
+		if !ok {
+			return fiber.ErrInternalServerError
+		}
+		record.ID = id
+	}
+
+	removed, err := s.archiveDocuments(record, steps, permanent)
+	if err != nil {
+		// Nothing was deleted yet, an incomplete copy must not show up as restorable
+		if !permanent {
+			s.dropArchive(record.ID)
+		}
+		return err
+	}
+
+	// Children go first so a failure never leaves them without a parent to be found by
+	for i := len(steps) - 1; i >= 0; i-- {
+		ids := removed[steps[i].collection]
+		if len(ids) == 0 {
+			continue
+		}
+
+		if err := s.db.Delete(s.dbCtx, steps[i].collection, bson.M{"_id": bson.M{"$in": ids}}); err != nil && err != types.ErrorNoMatches {
+			return err
+		}
+	}
+
+	// Archived licenses stop counting against the plan, a deleted owner takes its counter with it
+	if n := len(removed[mongo.Licenses]); n > 0 && record.Kind != mongo.ArchivedOwner {
+		return s.releaseLicenses(record.OwnerID, int64(n))
+	}
+
+	return nil
+}
+
+// archiveDocuments copies what each step matches into the archive, one document each, and returns the IDs to delete.
+func (s *Server) archiveDocuments(record *mongo.ArchiveObject, steps []cascade, permanent bool) (map[string][]primitive.ObjectID, error) {
+	removed := make(map[string][]primitive.ObjectID)
+	for i, step := range steps {
+		docs, err := s.db.Find(s.dbCtx, step.collection, step.query)
+		if err != nil {
+			return nil, err
+		}
+
+		copies := []any{}
+		for _, doc := range docs {
+			raw, err := bson.Marshal(doc)
+			if err != nil {
+				return nil, err
+			}
+
+			if id, ok := doc["_id"].(primitive.ObjectID); ok {
+				removed[step.collection] = append(removed[step.collection], id)
+			}
+
+			copies = append(copies, &mongo.ArchivedDocument{
+				ArchiveID:  record.ID,
+				Step:       i,
+				Collection: step.collection,
+				Data:       raw,
+				DeletedAt:  record.DeletedAt,
+			})
+		}
+
+		if permanent || len(copies) == 0 {
+			continue
+		}
+
+		if err := s.db.CreateMany(s.dbCtx, mongo.ArchivedDocuments, copies); err != nil {
+			return nil, err
+		}
+	}
+
+	return removed, nil
+}
+
+// dropArchive removes an archive along with its documents.
+func (s *Server) dropArchive(id primitive.ObjectID) error {
+	if err := s.db.Delete(s.dbCtx, mongo.ArchivedDocuments, bson.M{"archive_id": id}); err != nil && err != types.ErrorNoMatches {
+		log.Error(log.GetStackTrace(), "Could not drop archived documents, Error: %v", err.Error())
+		return err
+	}
+
+	return s.db.Delete(s.dbCtx, mongo.Archive, bson.M{"_id": id})
+}
+
+// archivedDocuments returns the documents of an archive with parents first.
+func (s *Server) archivedDocuments(record *mongo.ArchiveObject) ([]mongo.ArchivedDocument, error) {
+	if len(record.Documents) > 0 {
+		return record.Documents, nil
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "step", Value: 1}, {Key: "_id", Value: 1}})
+	unparsed, err := s.db.Find(s.dbCtx, mongo.ArchivedDocuments, bson.M{"archive_id": record.ID}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := []mongo.ArchivedDocument{}
+	if err := mongo.ReadAllInto[mongo.ArchivedDocument](unparsed, &docs); err != nil {
+		return nil, err
+	}
+
+	return docs, nil
+}
+
+// reserveRestore takes the plan slots an archive needs back before anything is restored, limits may have changed since the delete.
+func (s *Server) reserveRestore(record *mongo.ArchiveObject, docs []mongo.ArchivedDocument) error {
+	if record.Kind == mongo.ArchivedOwner {
+		return nil
+	}
+
+	owner, err := s.getOwner(record.OwnerID.Hex())
+	if err != nil {
+		return err
+	}
+
+	var licenses int64
+	for _, doc := range docs {
+		if doc.Collection == mongo.Licenses {
+			licenses++
+		}
+	}
+
+	if record.Kind == mongo.ArchivedApplication {
+		if err := s.reserveApp(owner, record.TargetID); err != nil {
+			return err
+		}
+	}
+
+	if licenses == 0 {
+		return nil
+	}
+
+	if err := s.reserveLicenses(owner, licenses); err != nil {
+		if record.Kind == mongo.ArchivedApplication {
+			if err := s.releaseApp(owner.ID, record.TargetID); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// restore puts an archive back in place and links it to its parent again.
+// Documents that already exist are skipped, so a restore that failed halfway can simply be run again.
+func (s *Server) restore(record *mongo.ArchiveObject) error {
+	retention := time.Duration(types.Cfg.Archive.Retention) * time.Second
+	if time.Since(time.Unix(record.DeletedAt, 0)) > retention {
+		return types.ErrorArchiveExpired
+	}
+
+	var parent string
+	switch record.Kind {
+	case mongo.ArchivedLicense:
+		parent = mongo.Applications
+	case mongo.ArchivedApplication:
+		parent = mongo.Owners
+	}
+
+	if parent != "" {
+		exists, err := s.db.Exists(s.dbCtx, parent, bson.M{"_id": record.ParentID})
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			return types.ErrorArchiveParent
+		}
+	}
+
+	docs, err := s.archivedDocuments(record)
+	if err != nil {
+		return err
+	}
+
+	// Only the first attempt takes the plan slots, the flag is given up again if the plan has no room
+	matched, err := s.db.Modify(s.dbCtx, mongo.Archive, bson.M{"_id": record.ID, "restoring": bson.M{"$ne": true}}, bson.M{"$set": bson.M{"restoring": true}})
+	if err != nil {
+		return err
+	}
+
+	if matched > 0 {
+		if err := s.reserveRestore(record, docs); err != nil {
+			if _, err := s.db.Modify(s.dbCtx, mongo.Archive, bson.M{"_id": record.ID}, bson.M{"$set": bson.M{"restoring": false}}); err != nil {
+				return err
+			}
+			return err
+		}
+	}
+
+	for _, doc := range docs {
+		if err := s.db.Create(s.dbCtx, doc.Collection, doc.Data); err != nil && !mongo.IsDuplicate(err) {
+			return err
+		}
+	}
+
+	// Applications were linked to their owner by reserveRestore
+	if record.Kind == mongo.ArchivedLicense {
+		if _, err := s.db.Modify(s.dbCtx, mongo.Applications, bson.M{"_id": record.ParentID}, bson.M{"$addToSet": bson.M{"licenses": record.TargetID}}); err != nil {
+			return err
+		}
+	}
+
+	return s.dropArchive(record.ID)
+}
+
+// RunArchivePurger permanently removes archives once their retention window has passed.
+func (s *Server) RunArchivePurger() {
+	for {
+		interval := time.Duration(max(types.Cfg.Archive.PurgeInterval, 1)) * time.Second
+		select {
+		case <-s.dbCtx.Done():
+			return
+		case <-time.After(interval):
+		}
+
+		cutoff := time.Now().Unix() - types.Cfg.Archive.Retention
+		for _, collection := range []string{mongo.ArchivedDocuments, mongo.Archive} {
+			if err := s.db.Delete(s.dbCtx, collection, bson.M{"deleted_at": bson.M{"$lt": cutoff}}); err != nil && err != types.ErrorNoMatches {
+				log.Error(log.GetStackTrace(), "Could not purge archive, Error: %v", err.Error())
+			}
+		}
+	}
+}
+
+// authorizeArchive lets staff through, anyone else must belong to the owner.
+// Staff are checked before the owner is loaded since the owner itself may be what was archived,
+// but an API token stays bound to its owner whoever it belongs to.
+func (s *Server) authorizeArchive(c fiber.Ctx, ownerID primitive.ObjectID) error {
+	fields, err := s.parseJWTFields(c)
+	if err != nil {
+		return err
+	}
+
+	if fields.OwnerID != "" && fields.OwnerID != ownerID.Hex() {
+		return fiber.ErrUnauthorized
+	}
+
+	if fields.Global() {
+		return nil
+	}
+
+	owner, err := s.getOwner(ownerID.Hex())
+	if err != nil {
+		return err
+	}
+
+	return s.verifyUser(c, owner)
+}
+
+// DeleteLicense archives a license, or removes it for good when permanent is set
+func (s *Server) DeleteLicense(c fiber.Ctx) error {
+	var msg DeleteMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	owner, app, err := s.authorizeApp(c, msg.OwnerID, msg.AppID)
+	if err != nil {
+		return err
+	}
+
+	license, err := s.getLicenseByID(msg.LicenseID, app)
+	if err != nil {
+		return err
+	}
+
+	record := &mongo.ArchiveObject{Kind: mongo.ArchivedLicense, TargetID: license.ID, OwnerID: owner.ID, ParentID: app.ID}
+	if err := s.archive(record, []cascade{{mongo.Licenses, bson.M{"_id": license.ID}}}, msg.Permanent); err != nil {
+		return err
+	}
+
+	if _, err := s.db.Modify(s.dbCtx, mongo.Applications, bson.M{"_id": app.ID}, bson.M{"$pull": bson.M{"licenses": license.ID}}); err != nil {
+		return err
+	}
+
+	return s.archiveResponse(c, record, msg.Permanent, session)
+}
+
+// DeleteApplication archives an application along with its licenses, variables and webhooks
+func (s *Server) DeleteApplication(c fiber.Ctx) error {
+	var msg DeleteMsg
+	session, err := s.parseAppBody(c, &msg, "LicenseID")
+	if err != nil {
+		return err
+	}
+
+	owner, app, err := s.authorizeApp(c, msg.OwnerID, msg.AppID)
+	if err != nil {
+		return err
+	}
+
+	record := &mongo.ArchiveObject{Kind: mongo.ArchivedApplication, TargetID: app.ID, OwnerID: owner.ID, ParentID: owner.ID}
+	if err := s.archive(record, appCascade([]primitive.ObjectID{app.ID}), msg.Permanent); err != nil {
+		return err
+	}
+
+	if err := s.releaseApp(owner.ID, app.ID); err != nil {
+		return err
+	}
+
+	return s.archiveResponse(c, record, msg.Permanent, session)
+}
+
+// DeleteOwner archives an owner and everything under it
+func (s *Server) DeleteOwner(c fiber.Ctx) error {
+	var msg DeleteMsg
+	session, err := s.parseAppBody(c, &msg, "AppID", "LicenseID")
+	if err != nil {
+		return err
+	}
+
+	owner, err := s.getOwner(msg.OwnerID)
+	if err != nil {
+		return err
+	}
+
+	record := &mongo.ArchiveObject{Kind: mongo.ArchivedOwner, TargetID: owner.ID, OwnerID: owner.ID}
+	if err := s.archive(record, ownerCascade(owner), msg.Permanent); err != nil {
+		return err
+	}
+
+	return s.archiveResponse(c, record, msg.Permanent, session)
+}
+
+func (s *Server) archiveResponse(c fiber.Ctx, record *mongo.ArchiveObject, permanent bool, session *Session) error {
+	returnDump := fiber.Map{"success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	if !permanent {
+		returnDump["archive_id"] = record.ID.Hex()
+		returnDump["restorable_until"] = record.DeletedAt + types.Cfg.Archive.Retention
+	}
+
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// ListArchive returns the deletions of an owner that can still be restored
+func (s *Server) ListArchive(c fiber.Ctx) error {
+	var msg ArchiveMsg
+	session, err := s.parseAppBody(c, &msg, "ArchiveID")
+	if err != nil {
+		return err
+	}
+
+	ownerID, err := primitive.ObjectIDFromHex(msg.OwnerID)
+	if err != nil {
+		return types.ErrorInvalidOwner
+	}
+
+	if err := s.authorizeArchive(c, ownerID); err != nil {
+		return err
+	}
+
+	cutoff := time.Now().Unix() - types.Cfg.Archive.Retention
+	query := bson.M{"owner_id": ownerID, "deleted_at": bson.M{"$gte": cutoff}}
+	opts := options.Find().SetSort(bson.D{{Key: "deleted_at", Value: -1}}).SetProjection(bson.M{"documents": 0})
+	unparsed, err := s.db.Find(s.dbCtx, mongo.Archive, query, opts)
+	if err != nil {
+		return err
+	}
+
+	var records []mongo.ArchiveObject
+	if err := mongo.ReadAllInto[mongo.ArchiveObject](unparsed, &records); err != nil {
+		return err
+	}
+
+	views := []fiber.Map{}
+	for _, v := range records {
+		views = append(views, fiber.Map{
+			"id":               v.ID.Hex(),
+			"kind":             v.Kind,
+			"target_id":        v.TargetID.Hex(),
+			"deleted_at":       v.DeletedAt,
+			"restorable_until": v.DeletedAt + types.Cfg.Archive.Retention,
+		})
+	}
+
+	returnDump := fiber.Map{"success": true, "archive": views, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// RestoreArchive undoes a delete within the retention window, restoring an owner needs the owner:delete permission
+func (s *Server) RestoreArchive(c fiber.Ctx) error {
+	var msg ArchiveMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	ownerID, err := primitive.ObjectIDFromHex(msg.OwnerID)
+	if err != nil {
+		return types.ErrorInvalidOwner
+	}
+
+	archiveID, err := primitive.ObjectIDFromHex(msg.ArchiveID)
+	if err != nil {
+		return types.ErrorInvalidArchive
+	}
+
+	if err := s.authorizeArchive(c, ownerID); err != nil {
+		return err
+	}
+
+	var record mongo.ArchiveObject
+	if err := s.db.Decode(s.dbCtx, mongo.Archive, bson.M{"_id": archiveID, "owner_id": ownerID}, &record, types.ErrorInvalidArchive); err != nil {
+		return err
+	}
+
+	if record.Kind == mongo.ArchivedOwner {
+		fields, err := s.parseJWTFields(c)
+		if err != nil {
+			return err
+		}
+
+		if !fields.Can(PermOwnerDelete) {
+			return types.ErrorForbidden
+		}
+	}
+
+	if err := s.restore(&record); err != nil {
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "id": record.TargetID.Hex(), "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
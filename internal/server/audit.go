@@ -0,0 +1,98 @@
+package server
+
+import (
+	"time"
+
+	mongo "github.com/Aran404/Goauth/internal/database/mongo"
+	log "github.com/Aran404/Goauth/internal/logger"
+	types "github.com/Aran404/Goauth/internal/types"
+	"github.com/gofiber/fiber/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// audit records an action taken on a user, c is nil for events the server raises itself.
+// Failing to write the entry is logged but doesn't undo the action.
+func (s *Server) audit(c fiber.Ctx, action string, target *mongo.UserObject, details map[string]string) {
+	entry := &mongo.AuditLogObject{Action: action, Details: details, CreatedAt: time.Now().Unix()}
+	if entry.Details == nil {
+		entry.Details = map[string]string{}
+	}
+
+	if target != nil {
+		entry.TargetID = target.ID.Hex()
+		entry.Target = target.Username
+	}
+
+	if c != nil {
+		entry.IP = c.IP()
+		if fields, err := s.parseJWTFields(c); err == nil {
+			entry.ActorID = fields.UserID
+			entry.Actor = fields.Username
+		}
+	}
+
+	if err := s.db.Create(s.dbCtx, mongo.AuditLogs, entry); err != nil {
+		log.Error(log.GetStackTrace(), "Could not write audit log, Action: %v, Error: %v", action, err.Error())
+	}
+}
+
+// ListAuditLogs pages through the audit log, newest first
+func (s *Server) ListAuditLogs(c fiber.Ctx) error {
+	var msg ListAuditLogsMsg
+	session, err := s.parseAppBody(c, &msg, "Action", "TargetID", "ActorID")
+	if err != nil {
+		return err
+	}
+
+	query := bson.M{}
+	if msg.Action != "" {
+		query["action"] = msg.Action
+	}
+
+	if msg.TargetID != "" {
+		query["target_id"] = msg.TargetID
+	}
+
+	if msg.ActorID != "" {
+		query["actor_id"] = msg.ActorID
+	}
+
+	if msg.Page < 1 {
+		msg.Page = 1
+	}
+
+	if msg.Limit < 1 || msg.Limit > maxPageSize {
+		msg.Limit = maxPageSize
+	}
+
+	total, err := s.db.Count(s.dbCtx, mongo.AuditLogs, query)
+	if err != nil {
+		return err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip((msg.Page - 1) * msg.Limit).
+		SetLimit(msg.Limit)
+
+	unparsed, err := s.db.Find(s.dbCtx, mongo.AuditLogs, query, opts)
+	if err != nil {
+		return err
+	}
+
+	logs := []mongo.AuditLogObject{}
+	if err := mongo.ReadAllInto[mongo.AuditLogObject](unparsed, &logs); err != nil {
+		return err
+	}
+
+	returnDump := fiber.Map{
+		"success": true,
+		"logs":    logs,
+		"total":   total,
+		"page":    msg.Page,
+		"limit":   msg.Limit,
+		"context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext),
+	}
+	return s.EncryptJson(c, returnDump, session)
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	crypto "github.com/Aran404/Goauth/internal/crypto"
+	mongo "github.com/Aran404/Goauth/internal/database/mongo"
 	"github.com/gofiber/fiber/v3"
 )
 
@@ -25,24 +26,334 @@ type (
 	}
 
 	UserJWT struct {
-		Username string  `json:"username"`
-		Exp      float64 `json:"exp"`
-		Admin    int8    `json:"admin"`
+		Username    string   `json:"username"`
+		UserID      string   `json:"user_id"`
+		SessionID   string   `json:"sid"`
+		Role        string   `json:"role"`
+		Permissions []string `json:"permissions"`
+		Exp         float64  `json:"exp"`
+		TwoFactor   bool     `json:"two_factor"`
+		// OwnerID is only set for API tokens, which are bound to a single owner
+		OwnerID string `json:"owner_id,omitempty"`
+	}
+
+	ChangePasswordMsg struct {
+		Password    string `json:"password"`
+		NewPassword string `json:"new_password"`
+	}
+
+	ChangeUsernameMsg struct {
+		Username string `json:"username"`
+	}
+
+	PasswordMsg struct {
+		Password string `json:"password"`
+	}
+
+	SetEmailMsg struct {
+		Password string `json:"password"`
+		Email    string `json:"email"`
+	}
+
+	EmailMsg struct {
+		Email string `json:"email"`
+	}
+
+	TokenMsg struct {
+		Token string `json:"token"`
+	}
+
+	ResetPasswordMsg struct {
+		Token       string `json:"token"`
+		NewPassword string `json:"new_password"`
+	}
+
+	CodeMsg struct {
+		Code string `json:"code"`
+	}
+
+	TwoFactorMsg struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+
+	TwoFactorLoginMsg struct {
+		Ticket string `json:"ticket"`
+		Code   string `json:"code"`
+	}
+
+	SessionIDMsg struct {
+		SessionID string `json:"session_id"`
+	}
+
+	RevokeSessionsMsg struct {
+		KeepCurrent bool `json:"keep_current"`
+	}
+
+	UserIDMsg struct {
+		UserID string `json:"user_id"`
+	}
+
+	ListUsersMsg struct {
+		Query     string `json:"query,omitempty"`
+		Role      string `json:"role,omitempty"`
+		Suspended *bool  `json:"suspended,omitempty"`
+		Page      int64  `json:"page,omitempty"`
+		Limit     int64  `json:"limit,omitempty"`
+	}
+
+	ListAuditLogsMsg struct {
+		Action   string `json:"action,omitempty"`
+		TargetID string `json:"target_id,omitempty"`
+		ActorID  string `json:"actor_id,omitempty"`
+		Page     int64  `json:"page,omitempty"`
+		Limit    int64  `json:"limit,omitempty"`
+	}
+
+	UserStatusMsg struct {
+		UserID    string `json:"user_id"`
+		Suspended bool   `json:"suspended"`
+		Reason    string `json:"reason"`
+		ExpiresAt int64  `json:"expires_at"`
+	}
+
+	RoleMsg struct {
+		UserID      string   `json:"user_id"`
+		Role        string   `json:"role"`
+		Permissions []string `json:"permissions,omitempty"`
 	}
 
 	NewApplicationMsg struct {
+		OwnerID         string `json:"owner_id"`
+		Name            string `json:"name"`
+		TrustOnFirstUse bool   `json:"trust_on_first_use,omitempty"`
+	}
+
+	AppMsg struct {
+		OwnerID string `json:"owner_id"`
+		AppID   string `json:"app_id"`
+	}
+
+	BuildMsg struct {
+		OwnerID  string `json:"owner_id"`
+		AppID    string `json:"app_id"`
+		Version  string `json:"version"`
+		Platform string `json:"platform"`
+		Hash     string `json:"hash"`
+	}
+
+	AppStatusMsg struct {
+		OwnerID string `json:"owner_id"`
+		AppID   string `json:"app_id"`
+		Status  string `json:"status"`
+		Message string `json:"message,omitempty"`
+	}
+
+	PolicyMsg struct {
+		OwnerID string              `json:"owner_id"`
+		AppID   string              `json:"app_id"`
+		Policy  *mongo.PolicyObject `json:"policy"`
+	}
+
+	WebhookMsg struct {
+		OwnerID string   `json:"owner_id"`
+		AppID   string   `json:"app_id"`
+		URL     string   `json:"url"`
+		Events  []string `json:"events,omitempty"`
+		Secret  string   `json:"secret,omitempty"`
+	}
+
+	WebhookIDMsg struct {
+		OwnerID   string `json:"owner_id"`
+		AppID     string `json:"app_id"`
+		WebhookID string `json:"webhook_id"`
+	}
+
+	DeliveriesMsg struct {
 		OwnerID string `json:"owner_id"`
+		AppID   string `json:"app_id"`
+		Status  string `json:"status,omitempty"`
+		Limit   int64  `json:"limit,omitempty"`
+	}
+
+	DeliveryIDMsg struct {
+		OwnerID    string `json:"owner_id"`
+		AppID      string `json:"app_id"`
+		DeliveryID string `json:"delivery_id"`
+	}
+
+	VariableMsg struct {
+		OwnerID string   `json:"owner_id"`
+		AppID   string   `json:"app_id"`
+		Name    string   `json:"name"`
+		Value   string   `json:"value"`
+		Tiers   []string `json:"tiers,omitempty"`
+	}
+
+	VariableNameMsg struct {
+		OwnerID string `json:"owner_id"`
+		AppID   string `json:"app_id"`
 		Name    string `json:"name"`
 	}
 
+	FetchVariableMsg struct {
+		Name string `json:"name"`
+	}
+
+	DeleteMsg struct {
+		OwnerID   string `json:"owner_id"`
+		AppID     string `json:"app_id,omitempty"`
+		LicenseID string `json:"license_id,omitempty"`
+		Permanent bool   `json:"permanent,omitempty"`
+	}
+
+	ArchiveMsg struct {
+		OwnerID   string `json:"owner_id"`
+		ArchiveID string `json:"archive_id,omitempty"`
+	}
+
+	InviteMsg struct {
+		OwnerID string   `json:"owner_id"`
+		Role    string   `json:"role"`
+		AppIDs  []string `json:"app_ids,omitempty"`
+	}
+
+	InvitationMsg struct {
+		Token string `json:"token"`
+	}
+
+	MemberMsg struct {
+		OwnerID string `json:"owner_id"`
+		UserID  string `json:"user_id"`
+	}
+
+	InvitationIDMsg struct {
+		OwnerID      string `json:"owner_id"`
+		InvitationID string `json:"invitation_id"`
+	}
+
+	APITokenMsg struct {
+		OwnerID   string   `json:"owner_id"`
+		Name      string   `json:"name"`
+		Scopes    []string `json:"scopes"`
+		ExpiresAt int64    `json:"expires_at,omitempty"`
+	}
+
+	APITokenIDMsg struct {
+		OwnerID string `json:"owner_id"`
+		TokenID string `json:"token_id"`
+	}
+
+	QuotaMsg struct {
+		AppID string `json:"app_id"`
+		Limit int64  `json:"limit"`
+	}
+
+	ResellerMsg struct {
+		OwnerID    string                 `json:"owner_id"`
+		ResellerID string                 `json:"reseller_id,omitempty"`
+		UserID     string                 `json:"user_id,omitempty"`
+		Name       string                 `json:"name,omitempty"`
+		Quotas     []QuotaMsg             `json:"quotas,omitempty"`
+		Templates  []mongo.TemplateObject `json:"templates,omitempty"`
+		Disabled   *bool                  `json:"disabled,omitempty"`
+	}
+
+	ResellerLicenseMsg struct {
+		OwnerID         string            `json:"owner_id"`
+		AppID           string            `json:"app_id"`
+		Template        string            `json:"template"`
+		Metadata        map[string]string `json:"metadata,omitempty"`
+		VisibleMetadata []string          `json:"visible_metadata,omitempty"`
+		Notes           string            `json:"notes,omitempty"`
+	}
+
+	AccessRuleMsg struct {
+		OwnerID   string `json:"owner_id"`
+		AppID     string `json:"app_id"`
+		Kind      string `json:"kind"`
+		Value     string `json:"value"`
+		Action    string `json:"action"`
+		Reason    string `json:"reason,omitempty"`
+		ExpiresAt int64  `json:"expires_at,omitempty"`
+	}
+
+	AccessRuleIDMsg struct {
+		OwnerID string `json:"owner_id"`
+		AppID   string `json:"app_id"`
+		RuleID  string `json:"rule_id"`
+	}
+
+	PlanMsg struct {
+		OwnerID          string `json:"owner_id"`
+		MaxApplications  int64  `json:"max_applications"`
+		MaxLicenses      int64  `json:"max_licenses"`
+		MaxActiveDevices int64  `json:"max_active_devices"`
+	}
+
+	OwnerMsg struct {
+		OwnerID string `json:"owner_id"`
+	}
+
+	BuildStatusMsg struct {
+		OwnerID string `json:"owner_id"`
+		AppID   string `json:"app_id"`
+		Hash    string `json:"hash"`
+		Status  string `json:"status"`
+	}
+
 	NewLicenseMsg struct {
-		OwnerID       string `json:"owner_id"`
-		Expiry        uint64 `json:"expiry"`
-		AppID         string `json:"app_id"`
-		AppName       string `json:"name"`
-		Mask          string `json:"mask,omitempty"`
-		OnlyCapitals  bool   `json:"include_capitals,omitempty"`
-		OnlyLowercase bool   `json:"include_lowercase,omitempty"`
+		OwnerID         string            `json:"owner_id"`
+		Expiry          uint64            `json:"expiry"`
+		AppID           string            `json:"app_id"`
+		AppName         string            `json:"name"`
+		Mask            string            `json:"mask,omitempty"`
+		OnlyCapitals    bool              `json:"include_capitals,omitempty"`
+		OnlyLowercase   bool              `json:"include_lowercase,omitempty"`
+		Metadata        map[string]string `json:"metadata,omitempty"`
+		VisibleMetadata []string          `json:"visible_metadata,omitempty"`
+		Notes           string            `json:"notes,omitempty"`
+		Tier            string            `json:"tier,omitempty"`
+	}
+
+	UpdateLicenseMsg struct {
+		OwnerID         string            `json:"owner_id"`
+		AppID           string            `json:"app_id"`
+		LicenseID       string            `json:"license_id"`
+		Metadata        map[string]string `json:"metadata,omitempty"`
+		VisibleMetadata []string          `json:"visible_metadata,omitempty"`
+		Notes           *string           `json:"notes,omitempty"`
+		Tier            *string           `json:"tier,omitempty"`
+	}
+
+	LicenseIDMsg struct {
+		OwnerID   string `json:"owner_id"`
+		AppID     string `json:"app_id"`
+		LicenseID string `json:"license_id"`
+	}
+
+	SearchLicensesMsg struct {
+		OwnerID  string            `json:"owner_id"`
+		AppID    string            `json:"app_id"`
+		Metadata map[string]string `json:"metadata,omitempty"`
+		Notes    string            `json:"notes,omitempty"`
+		Page     int64             `json:"page,omitempty"`
+		Limit    int64             `json:"limit,omitempty"`
+	}
+
+	ListLicensesMsg struct {
+		OwnerID        string            `json:"owner_id"`
+		AppID          string            `json:"app_id"`
+		Status         string            `json:"status,omitempty"`
+		Activated      *bool             `json:"activated,omitempty"`
+		ExpiringBefore uint64            `json:"expiring_before,omitempty"`
+		Fingerprint    string            `json:"fingerprint,omitempty"`
+		ResellerID     string            `json:"reseller_id,omitempty"`
+		Metadata       map[string]string `json:"metadata,omitempty"`
+		Sort           string            `json:"sort,omitempty"`
+		Descending     bool              `json:"descending,omitempty"`
+		Page           int64             `json:"page,omitempty"`
+		Limit          int64             `json:"limit,omitempty"`
 	}
 )
 
@@ -0,0 +1,305 @@
+package server
+
+import (
+	"net/mail"
+	"strings"
+	"time"
+
+	crypto "github.com/Aran404/Goauth/internal/crypto"
+	mongo "github.com/Aran404/Goauth/internal/database/mongo"
+	log "github.com/Aran404/Goauth/internal/logger"
+	types "github.com/Aran404/Goauth/internal/types"
+	utils "github.com/Aran404/Goauth/internal/utils"
+	"github.com/gofiber/fiber/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	verifyTokenPrefix = "verify_email:"
+	resetTokenPrefix  = "reset_password:"
+	resetCooldown     = time.Minute
+)
+
+// normalizeEmail only accepts a bare address, names and angle brackets are refused.
+func normalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	parsed, err := mail.ParseAddress(email)
+	if err != nil || parsed.Name != "" || parsed.Address != email {
+		return "", types.ErrorInvalidEmail
+	}
+
+	return strings.ToLower(email), nil
+}
+
+// issueEmailToken stores a single use token under its keyed hash, the plain token is only ever emailed.
+func (s *Server) issueEmailToken(prefix, value string, expiry int64) (string, error) {
+	token, err := crypto.GenerateAPIKey(32)
+	if err != nil {
+		return "", err
+	}
+
+	ttl := time.Duration(max(expiry, 60)) * time.Second
+	if err := s.rdb.Set(s.dbCtx, prefix+crypto.KeyedHash(token, s.licensePepper), value, ttl); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// peekEmailToken returns what a token was issued for without using it up.
+func (s *Server) peekEmailToken(prefix, token string) (string, error) {
+	value, err := s.rdb.Get(s.dbCtx, prefix+crypto.KeyedHash(token, s.licensePepper))
+	if err != nil {
+		if err == types.ErrorNotFound {
+			return "", types.ErrorInvalidToken
+		}
+		return "", err
+	}
+
+	return value, nil
+}
+
+// useEmailToken returns what a token was issued for and removes it, so it can't be used twice.
+func (s *Server) useEmailToken(prefix, token string) (string, error) {
+	value, err := s.rdb.GetDel(s.dbCtx, prefix+crypto.KeyedHash(token, s.licensePepper))
+	if err != nil {
+		if err == types.ErrorNotFound {
+			return "", types.ErrorInvalidToken
+		}
+		return "", err
+	}
+
+	return value, nil
+}
+
+// sendMail sends in the background, a slow mail server shouldn't hold up the request or tell whether an email exists.
+func (s *Server) sendMail(to, subject, body string) {
+	go func() {
+		if err := s.mailer.Send(to, subject, body); err != nil {
+			log.Error(log.GetStackTrace(), "Could not send mail, Error: %v", err.Error())
+		}
+	}()
+}
+
+func (s *Server) sendVerification(user *mongo.UserObject, email string) error {
+	token, err := s.issueEmailToken(verifyTokenPrefix, user.ID.Hex()+":"+email, types.Cfg.Security.VerifyEmailExpiry)
+	if err != nil {
+		return err
+	}
+
+	s.sendMail(email, "Verify your email", "Hi "+user.Username+",\n\nUse this token to verify your email address:\n\n"+token+"\n")
+	return nil
+}
+
+// SetEmail adds or changes the email of the account, it has to be verified before it can be used to reset the password
+func (s *Server) SetEmail(c fiber.Ctx) error {
+	var msg SetEmailMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	email, err := normalizeEmail(msg.Email)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkPasswordAttempt(user, msg.Password, c.IP()); err != nil {
+		return err
+	}
+
+	// Whether the address is taken is only told to whoever can read its inbox, at /verify-email
+	if err := s.db.Update(s.dbCtx, mongo.Users, bson.M{"_id": user.ID}, bson.M{"email": email, "email_verified": false}); err != nil {
+		return err
+	}
+
+	if err := s.sendVerification(user, email); err != nil {
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+func (s *Server) ResendVerification(c fiber.Ctx) error {
+	session, _, err := s.ParseBody(c)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	if user.Email == "" {
+		return types.ErrorNoEmail
+	}
+
+	if user.EmailVerified {
+		return types.ErrorEmailVerified
+	}
+
+	if err := s.sendVerification(user, user.Email); err != nil {
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// VerifyEmail uses up a verification token, it fails if the email was changed after the token was sent.
+// A verified address belongs to that account alone, other accounts that only added it lose it.
+func (s *Server) VerifyEmail(c fiber.Ctx) error {
+	var msg TokenMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	value, err := s.useEmailToken(verifyTokenPrefix, msg.Token)
+	if err != nil {
+		return err
+	}
+
+	userID, email, _ := strings.Cut(value, ":")
+	id, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return types.ErrorInvalidToken
+	}
+
+	exists, err := s.db.Exists(s.dbCtx, mongo.Users, bson.M{"email": email, "email_verified": true, "_id": bson.M{"$ne": id}})
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return types.ErrorEmailExists
+	}
+
+	// The unique index on verified emails refuses the loser of two verifications racing each other
+	matched, err := s.db.Modify(s.dbCtx, mongo.Users, bson.M{"_id": id, "email": email}, bson.M{"$set": bson.M{"email_verified": true}})
+	if err != nil {
+		if mongo.IsDuplicate(err) {
+			return types.ErrorEmailExists
+		}
+		return err
+	}
+
+	if matched == 0 {
+		return types.ErrorInvalidToken
+	}
+
+	unclaimed := bson.M{"email": email, "email_verified": false, "_id": bson.M{"$ne": id}}
+	if _, err := s.db.ModifyMany(s.dbCtx, mongo.Users, unclaimed, bson.M{"$unset": bson.M{"email": ""}}); err != nil {
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// ForgotPassword emails a reset token to a verified address.
+// The response is the same whether or not an account has the email, so it can't be used to look accounts up.
+func (s *Server) ForgotPassword(c fiber.Ctx) error {
+	var msg EmailMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	email, err := normalizeEmail(msg.Email)
+	if err != nil {
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+
+	dump, err := s.db.Filter(s.dbCtx, mongo.Users, bson.M{"email": email, "email_verified": true}, false, types.ErrorUserNotFound)
+	if err != nil {
+		if err == types.ErrorUserNotFound {
+			return s.EncryptJson(c, returnDump, session)
+		}
+		return err
+	}
+
+	var user mongo.UserObject
+	if err := mongo.ReadInto[mongo.UserObject](dump, &user); err != nil {
+		return err
+	}
+
+	// One email a minute per account is enough, anything more is someone flooding an inbox
+	// Taking the cooldown is the check, so requests racing each other still send one email
+	first, err := s.rdb.SetNX(s.dbCtx, "reset_cooldown:"+user.ID.Hex(), 1, resetCooldown)
+	if err != nil {
+		return err
+	}
+
+	if first {
+		token, err := s.issueEmailToken(resetTokenPrefix, user.ID.Hex(), types.Cfg.Security.ResetExpiry)
+		if err != nil {
+			return err
+		}
+
+		s.sendMail(email, "Reset your password", "Hi "+user.Username+",\n\nUse this token to reset your password:\n\n"+token+"\n\nIf you didn't ask for this, you can ignore this email.\n")
+	}
+
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// ResetPassword sets a new password with a reset token and signs out every session, the account logs in again afterwards
+func (s *Server) ResetPassword(c fiber.Ctx) error {
+	var msg ResetPasswordMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	// The token is only used up once the new password is accepted, a weak one can be corrected with the same token
+	userID, err := s.peekEmailToken(resetTokenPrefix, msg.Token)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.getUser(userID)
+	if err != nil {
+		if err == types.ErrorUserNotFound {
+			return types.ErrorInvalidToken
+		}
+		return err
+	}
+
+	if err := utils.CheckPassword(msg.NewPassword, user.Username, &types.Cfg.PasswordPolicy); err != nil {
+		return err
+	}
+
+	hashed, err := s.passwords.Hash(msg.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	// Another request may have used the token in the meantime
+	if used, err := s.useEmailToken(resetTokenPrefix, msg.Token); err != nil {
+		return err
+	} else if used != userID {
+		return types.ErrorInvalidToken
+	}
+
+	if err := s.db.Update(s.dbCtx, mongo.Users, bson.M{"_id": user.ID}, bson.M{"password": hashed}); err != nil {
+		return err
+	}
+
+	if err := s.revokeSessions(user.ID, ""); err != nil {
+		return err
+	}
+	s.loginSucceeded(user.Username)
+
+	returnDump := fiber.Map{"success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
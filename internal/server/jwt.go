@@ -5,37 +5,53 @@ import (
 	"errors"
 	"time"
 
+	crypto "github.com/Aran404/Goauth/internal/crypto"
+	mongo "github.com/Aran404/Goauth/internal/database/mongo"
 	types "github.com/Aran404/Goauth/internal/types"
 	utils "github.com/Aran404/Goauth/internal/utils"
 	"github.com/gofiber/fiber/v3"
 	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func (s *Server) GenerateKeyPair(username string, admin int8) (*fiber.Map, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.MapClaims{
-		"username": username,
-		"admin":    admin,
-		"exp":      time.Now().Add(time.Minute * time.Duration(types.Cfg.Crypto.AccessTokenExpiry)).Unix(),
-	})
+// accessClaims carries the user's role and permissions so routes can be authorized without a database lookup.
+// The session ID ties the token to its refresh session, revoking the session stops the token too.
+func accessClaims(user *mongo.UserObject, sessionID string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"username":    user.Username,
+		"user_id":     user.ID.Hex(),
+		"sid":         sessionID,
+		"role":        user.GetRole(),
+		"permissions": Permissions(user),
+		"two_factor":  user.TwoFactor.Enabled,
+		"exp":         time.Now().Add(time.Minute * time.Duration(types.Cfg.Crypto.AccessTokenExpiry)).Unix(),
+	}
+}
 
+// GenerateKeyPair signs an access and a refresh token for a session, the refresh token's expiry is returned with them.
+func (s *Server) GenerateKeyPair(user *mongo.UserObject, sessionID string) (*fiber.Map, int64, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS512, accessClaims(user, sessionID))
+
+	// Roles are read again on refresh, so the refresh token only needs to know its session
+	refreshExp := time.Now().Add(time.Minute * time.Duration(types.Cfg.Crypto.RefreshTokenExpiry)).Unix()
 	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.MapClaims{
-		"username": username,
-		"admin":    admin,
-		"exp":      time.Now().Add(time.Minute * time.Duration(types.Cfg.Crypto.RefreshTokenExpiry)).Unix(),
+		"sid": sessionID,
+		"exp": refreshExp,
 	})
 
 	signedToken, err := token.SignedString(s.jwtSecret)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	signedRefreshToken, err := refreshToken.SignedString(s.jwtSecret)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	dump := &fiber.Map{"token": signedToken, "refresh_token": signedRefreshToken, "success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
-	return dump, nil
+	return dump, refreshExp, nil
 }
 
 func (s *Server) RefreshAccessToken(c fiber.Ctx) error {
@@ -55,7 +71,7 @@ func (s *Server) RefreshAccessToken(c fiber.Ctx) error {
 	}
 
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		username, ok := claims["username"].(string)
+		sid, ok := claims["sid"].(string)
 		if !ok {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid token claims"})
 		}
@@ -70,10 +86,39 @@ func (s *Server) RefreshAccessToken(c fiber.Ctx) error {
 			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Refresh token expired"})
 		}
 
-		newToken := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.MapClaims{
-			"username": username,
-			"exp":      time.Now().Add(time.Minute * time.Duration(types.Cfg.Crypto.AccessTokenExpiry)).Unix(),
-		})
+		sessionID, err := primitive.ObjectIDFromHex(sid)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid token claims"})
+		}
+
+		// The session has to still exist, revoking it is what signs a device out
+		query := bson.M{"_id": sessionID, "token": crypto.KeyedHash(refreshToken, s.licensePepper)}
+		unparsed, err := s.db.Filter(s.dbCtx, mongo.RefreshSessions, query, false, types.ErrorSessionNotFound)
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid refresh token"})
+		}
+
+		var session mongo.RefreshSessionObject
+		if err := mongo.ReadInto[mongo.RefreshSessionObject](unparsed, &session); err != nil {
+			return err
+		}
+
+		// A role change applies from the next refresh
+		user, err := s.getUser(session.UserID.Hex())
+		if err != nil {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid token claims"})
+		}
+
+		if err := accountStatus(user); err != nil {
+			return err
+		}
+
+		update := bson.M{"$set": bson.M{"last_used": time.Now().Unix(), "ip": c.IP()}}
+		if _, err := s.db.Modify(s.dbCtx, mongo.RefreshSessions, bson.M{"_id": session.ID}, update); err != nil {
+			return err
+		}
+
+		newToken := jwt.NewWithClaims(jwt.SigningMethodHS512, accessClaims(user, sid))
 
 		signedToken, err := newToken.SignedString(s.jwtSecret)
 		if err != nil {
@@ -107,7 +152,7 @@ func (s *Server) parseJWTFields(c fiber.Ctx) (*UserJWT, error) {
 		return nil, types.ErrorInvalidJSON
 	}
 
-	if utils.CheckEmptyFields(msg) {
+	if utils.CheckEmptyFields(msg, "OwnerID", "SessionID") {
 		return nil, types.ErrorEmptyFields
 	}
 
@@ -2,9 +2,13 @@ package server
 
 import (
 	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 
+	crypto "github.com/Aran404/Goauth/internal/crypto"
 	mongo "github.com/Aran404/Goauth/internal/database/mongo"
+	log "github.com/Aran404/Goauth/internal/logger"
 	types "github.com/Aran404/Goauth/internal/types"
 	utils "github.com/Aran404/Goauth/internal/utils"
 	"github.com/gofiber/fiber/v3"
@@ -27,73 +31,283 @@ func (s *Server) VerifyLicense(c fiber.Ctx) error {
 		return err
 	}
 
-	if err := s.validateFields(holder.msg, holder.license, holder.app); err != nil {
+	if err := checkAppStatus(holder.app); err != nil {
 		return err
 	}
 
+	if err := s.checkAccess(holder.app.ID, c.IP(), holder.msg.Fingerprint, "license validation"); err != nil {
+		s.recordFailure(holder, err)
+		return err
+	}
+
+	if holder.license.Revoked {
+		s.recordFailure(holder, types.ErrorRevokedLicense)
+		return types.ErrorRevokedLicense
+	}
+
+	// The build is checked first so an unknown binary can never activate a license
+	build, err := s.checkBuild(holder.msg, holder.app)
+	if err != nil {
+		s.recordFailure(holder, err)
+		return err
+	}
+
+	claimed, err := s.claimDevice(holder.owner, holder.license, holder.app.GetPolicy(), holder.msg.Fingerprint)
+	if err != nil {
+		s.recordFailure(holder, err)
+		return err
+	}
+
+	activated, err := s.validateFields(holder.msg, c.IP(), holder.license, holder.app)
+
+	// The slot stays taken when the device was bound, even if a later check failed
+	if claimed && !utils.ArrayContains(holder.license.Devices, holder.msg.Fingerprint) {
+		if err := s.releaseDevice(holder.owner.ID); err != nil {
+			log.Error(log.GetStackTrace(), "Could not release device slot, Error: %v", err.Error())
+		}
+	}
+
+	if err != nil {
+		s.recordFailure(holder, err)
+		return err
+	}
+
+	if activated {
+		s.emitEvent(holder.app.ID, EventLicenseActivated, fiber.Map{
+			"license": licenseView(holder.license),
+			"ip":      c.IP(),
+		})
+	}
+
 	plainText := fiber.Map{
 		"success": true,
 		"context": uint64(time.Now().Unix()) + types.Cfg.Security.AllowedContext,
 	}
 
+	if build.Status == mongo.BuildDeprecated {
+		plainText["deprecated"] = true
+	}
+
+	// Clients may keep running offline until the grace period passes
+	if grace := holder.app.GetPolicy().OfflineGracePeriod; grace > 0 {
+		plainText["offline_until"] = min(uint64(time.Now().Unix())+grace, *holder.license.Expiry)
+	}
+
+	if visible := visibleMetadata(holder.license); len(visible) > 0 {
+		plainText["metadata"] = visible
+	}
+
+	// The session may now fetch the application's variables
+	if err := s.rdb.Set(s.dbCtx, validatedKey(c.Get("X-Session-Id")), holder.license.ID.Hex(), time.Duration(types.Cfg.DestroySession)*time.Second); err != nil {
+		return err
+	}
+
 	return s.EncryptJson(c, plainText, session)
 }
 
-func (s *Server) updateLicense(l *mongo.LicenseObject) error {
-	return s.db.Update(s.dbCtx, mongo.Licenses, bson.M{"_id": l.ID}, l)
+// visibleMetadata returns the metadata the owner has allowed clients to see.
+func visibleMetadata(l *mongo.LicenseObject) map[string]string {
+	visible := make(map[string]string)
+	for _, k := range l.VisibleMetadata {
+		if v, ok := l.Metadata[k]; ok {
+			visible[k] = v
+		}
+	}
+
+	return visible
+}
+
+// validMetadata makes sure metadata keys can't be used to inject mongo operators or paths.
+func validMetadata(m map[string]string) bool {
+	for k := range m {
+		if k == "" || strings.HasPrefix(k, "$") || strings.Contains(k, ".") {
+			return false
+		}
+	}
+
+	return true
 }
 
-func (s *Server) updateApplication(l *mongo.ApplicationObject) error {
-	return s.db.Update(s.dbCtx, mongo.Applications, bson.M{"_id": l.ID}, l)
+// Verify the licenses validity against the application's policy, reporting whether this call activated the license
+func (s *Server) validateFields(msg *LicenseMsg, ip string, l *mongo.LicenseObject, app *mongo.ApplicationObject) (bool, error) {
+	policy := app.GetPolicy()
+
+	activated := false
+	if len(l.Devices) == 0 {
+		var err error
+		if activated, err = s.bindLicense(l, msg.Fingerprint); err != nil {
+			return false, err
+		}
+	}
+
+	if policy.EnforceFingerprint {
+		if err := s.claimSlot(l, "devices", msg.Fingerprint, max(policy.MaxDevices, 1)); err != nil {
+			if err == types.ErrorNoMatches {
+				return false, types.ErrorInvalidFingerprint
+			}
+			return false, err
+		}
+	}
+
+	if policy.MaxIPs > 0 {
+		if err := s.claimSlot(l, "ips", ip, policy.MaxIPs); err != nil {
+			if err == types.ErrorNoMatches {
+				return false, types.ErrorIPLimit
+			}
+			return false, err
+		}
+	}
+
+	if uint64(time.Now().Unix()) > *l.Expiry {
+		return false, types.ErrorExpiredLicense
+	}
+
+	return activated, nil
 }
 
-// Verify the licenses validity
-func (s *Server) validateFields(msg *LicenseMsg, l *mongo.LicenseObject, app *mongo.ApplicationObject) error {
-	licenseCheck := false
-	appCheck := false
+// bindLicense starts the expiry of a license used for the first time and binds it to its first device.
+// Licenses activated before device limits existed keep their expiry and are bound to the fingerprint they already have.
+// Only the first of several concurrent activations binds, the license is read again so every caller sees the winner.
+// It reports whether this call started the expiry.
+func (s *Server) bindLicense(l *mongo.LicenseObject, fingerprint string) (bool, error) {
+	if l.Fingerprint != nil {
+		fingerprint = *l.Fingerprint
+	}
 
-	// The license is being used for the first time
+	set := bson.M{"fingerprint": fingerprint, "devices": []string{fingerprint}}
 	if l.Expiry == nil {
-		licenseCheck = true
-		period := uint64(time.Now().Unix()) + l.ExpectedExpiry
-		l.Expiry = &period
+		set["expiry"] = uint64(time.Now().Unix()) + l.ExpectedExpiry
+	}
+
+	query := bson.M{"_id": l.ID, "devices.0": bson.M{"$exists": false}}
+	matched, err := s.db.Modify(s.dbCtx, mongo.Licenses, query, bson.M{"$set": set})
+	if err != nil {
+		return false, err
 	}
 
-	if l.Fingerprint == nil {
-		licenseCheck = true
-		l.Fingerprint = &msg.Fingerprint
+	unparsed, err := s.db.Filter(s.dbCtx, mongo.Licenses, bson.M{"_id": l.ID}, false, types.ErrorInvalidLicense)
+	if err != nil {
+		return false, err
+	}
+
+	var fresh mongo.LicenseObject
+	if err := mongo.ReadInto[mongo.LicenseObject](unparsed, &fresh); err != nil {
+		return false, err
+	}
+
+	if fresh.Revoked {
+		return false, types.ErrorRevokedLicense
 	}
 
-	if app.IntegritySignature == nil {
-		appCheck = true
-		app.IntegritySignature = &msg.IntegritySignature
+	if fresh.Expiry == nil {
+		return false, types.ErrorInvalidLicense
 	}
 
-	if licenseCheck {
-		if err := s.updateLicense(l); err != nil {
-			return err
+	activated := matched > 0 && l.Expiry == nil
+	*l = fresh
+	return activated, nil
+}
+
+// claimSlot makes sure a value is in one of the license's bounded lists, adding it if there is room.
+// The size check is part of the update query so concurrent activations can't go over the limit.
+func (s *Server) claimSlot(l *mongo.LicenseObject, field, value string, limit int) error {
+	var current []string
+	switch field {
+	case "devices":
+		current = l.Devices
+	case "ips":
+		current = l.IPs
+	}
+
+	if utils.ArrayContains(current, value) {
+		return nil
+	}
+
+	query := bson.M{"_id": l.ID, fmt.Sprintf("%s.%d", field, limit-1): bson.M{"$exists": false}}
+	matched, err := s.db.Modify(s.dbCtx, mongo.Licenses, query, bson.M{"$addToSet": bson.M{field: value}})
+	if err != nil {
+		return err
+	}
+
+	if matched == 0 {
+		return types.ErrorNoMatches
+	}
+
+	switch field {
+	case "devices":
+		l.Devices = append(l.Devices, value)
+	case "ips":
+		l.IPs = append(l.IPs, value)
+	}
+
+	return nil
+}
+
+// checkAppStatus enforces the owner's kill switch.
+// The application is read fresh on every validation, so a change applies to every replica immediately.
+func checkAppStatus(app *mongo.ApplicationObject) error {
+	if app.Frozen {
+		return types.ErrorApplicationFrozen
+	}
+
+	switch app.Status {
+	case mongo.AppDisabled:
+		return types.WithMessage(types.ErrorApplicationDisabled, app.StatusMessage)
+	case mongo.AppMaintenance:
+		return types.WithMessage(types.ErrorApplicationMaintenance, app.StatusMessage)
+	}
+
+	return nil
+}
+
+// checkBuild makes sure the client is running one of the builds registered for the application.
+func (s *Server) checkBuild(msg *LicenseMsg, app *mongo.ApplicationObject) (*mongo.BuildObject, error) {
+	policy := app.GetPolicy()
+
+	// Applications pinned before the build registry existed have the pin moved into it, where it can be blocked like any build
+	if app.IntegritySignature != nil {
+		if err := moveIntegrityPin(s.dbCtx, s.db, app); err != nil {
+			return nil, err
 		}
 	}
 
-	if appCheck {
-		if err := s.updateApplication(app); err != nil {
-			return err
+	hash := strings.ToLower(msg.IntegritySignature)
+	if build := app.FindBuild(hash); build != nil {
+		if build.Status == mongo.BuildBlocked {
+			return nil, types.ErrorBlockedBuild
 		}
+		return build, nil
 	}
 
-	if msg.Fingerprint != *l.Fingerprint {
-		return types.ErrorInvalidFingerprint
+	// Blocked builds above are still refused, anything else is let through
+	if !policy.EnforceIntegrity {
+		return &mongo.BuildObject{Hash: hash, Status: mongo.BuildActive}, nil
 	}
 
-	if msg.IntegritySignature != *app.IntegritySignature {
-		return types.ErrorInvalidFingerprint
+	if !policy.AllowTrustOnFirstUse || len(app.Builds) > 0 {
+		return nil, types.ErrorInvalidBuild
 	}
 
-	if uint64(time.Now().Unix()) > *l.Expiry {
-		return types.ErrorExpiredLicense
+	build := mongo.BuildObject{
+		Version:   "first-use",
+		Hash:      hash,
+		Status:    mongo.BuildActive,
+		CreatedAt: time.Now().Unix(),
 	}
 
-	return nil
+	// Only the very first caller may pin the build, a concurrent caller loses the race
+	query := bson.M{"_id": app.ID, "builds.0": bson.M{"$exists": false}}
+	matched, err := s.db.Modify(s.dbCtx, mongo.Applications, query, bson.M{"$push": bson.M{"builds": build}})
+	if err != nil {
+		return nil, err
+	}
+
+	if matched == 0 {
+		return nil, types.ErrorInvalidBuild
+	}
+
+	app.Builds = append(app.Builds, build)
+	return &build, nil
 }
 
 // ? Maybe this function is doing too much
@@ -107,27 +321,39 @@ func (s *Server) collectHolders(body []byte) (*LicenseHolders, error) {
 		return nil, types.ErrorEmptyFields
 	}
 
-	owner, err := s.getOwner(License)
-	if err != nil {
-		return nil, err
+	// Typos are caught here before the owner and application are loaded.
+	// Keys issued before checksums existed have none, so a failed checksum is only final once the license says it has one.
+	var proper *mongo.LicenseObject
+	if !utils.ValidLicenseChecksum(License.LicenseKey, License.AppID) {
+		legacy, err := s.getLicense(License)
+		if err != nil || legacy.Checksummed {
+			return nil, types.ErrorMalformedLicense
+		}
+		proper = legacy
 	}
 
-	application, err := s.getApplication(License, owner)
+	owner, err := s.getOwner(License.OwnerID)
 	if err != nil {
 		return nil, err
 	}
 
-	proper, err := s.getLicense(License)
+	application, err := s.getApplication(License.AppID, owner)
 	if err != nil {
 		return nil, err
 	}
 
-	return &LicenseHolders{License, proper, application}, nil
+	if proper == nil {
+		if proper, err = s.getLicense(License); err != nil {
+			return nil, err
+		}
+	}
+
+	return &LicenseHolders{License, proper, application, owner}, nil
 }
 
-func (s *Server) getApplication(l *LicenseMsg, owner *mongo.OwnerObject) (*mongo.ApplicationObject, error) {
+func (s *Server) getApplication(id string, owner *mongo.OwnerObject) (*mongo.ApplicationObject, error) {
 	// Verify AppID
-	appID, err := primitive.ObjectIDFromHex(l.AppID)
+	appID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, types.ErrorInvalidApp
 	}
@@ -149,9 +375,9 @@ func (s *Server) getApplication(l *LicenseMsg, owner *mongo.OwnerObject) (*mongo
 	return &application, nil
 }
 
-func (s *Server) getOwner(l *LicenseMsg) (*mongo.OwnerObject, error) {
+func (s *Server) getOwner(id string) (*mongo.OwnerObject, error) {
 	// Verify OwnerID
-	ownerID, err := primitive.ObjectIDFromHex(l.OwnerID)
+	ownerID, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
 		return nil, types.ErrorInvalidOwner
 	}
@@ -171,7 +397,38 @@ func (s *Server) getOwner(l *LicenseMsg) (*mongo.OwnerObject, error) {
 
 func (s *Server) getLicense(l *LicenseMsg) (*mongo.LicenseObject, error) {
 	// Verify License
-	licenseObj, err := s.db.Filter(s.dbCtx, mongo.Licenses, bson.M{"key": l.LicenseKey}, false, types.ErrorInvalidLicense)
+	hashed := crypto.KeyedHash(l.LicenseKey, s.licensePepper)
+	licenseObj, err := s.db.Filter(s.dbCtx, mongo.Licenses, bson.M{"key": hashed}, false, types.ErrorInvalidLicense)
+	if err != nil {
+		return nil, err
+	}
+
+	var license mongo.LicenseObject
+	if err := mongo.ReadInto[mongo.LicenseObject](licenseObj, &license); err != nil {
+		return nil, err
+	}
+
+	return &license, nil
+}
+
+const hintLength = 4
+
+// licenseHint keeps the tail of a key so owners can tell licenses apart without the plain text.
+func licenseHint(key string) string {
+	if len(key) <= hintLength {
+		return key
+	}
+
+	return key[len(key)-hintLength:]
+}
+
+func (s *Server) getLicenseByID(id string, app *mongo.ApplicationObject) (*mongo.LicenseObject, error) {
+	licenseID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, types.ErrorInvalidLicense
+	}
+
+	licenseObj, err := s.db.Filter(s.dbCtx, mongo.Licenses, bson.M{"_id": licenseID, "app_id": app.ID}, false, types.ErrorInvalidLicense)
 	if err != nil {
 		return nil, err
 	}
@@ -183,3 +440,31 @@ func (s *Server) getLicense(l *LicenseMsg) (*mongo.LicenseObject, error) {
 
 	return &license, nil
 }
+
+// licenseView is what owners get to see of a license, the key hash is never returned.
+func licenseView(l *mongo.LicenseObject) fiber.Map {
+	view := fiber.Map{
+		"id":               l.ID.Hex(),
+		"app_id":           l.Application.Hex(),
+		"hint":             l.Hint,
+		"fingerprint":      l.Fingerprint,
+		"expected_expiry":  l.ExpectedExpiry,
+		"expiry":           l.Expiry,
+		"metadata":         l.Metadata,
+		"visible_metadata": l.VisibleMetadata,
+		"notes":            l.Notes,
+		"revoked":          l.Revoked,
+		"tier":             l.Tier,
+	}
+
+	// Licenses created before attribution existed have neither
+	if !l.CreatedBy.IsZero() {
+		view["created_by"] = l.CreatedBy.Hex()
+	}
+
+	if !l.ResellerID.IsZero() {
+		view["reseller_id"] = l.ResellerID.Hex()
+	}
+
+	return view
+}
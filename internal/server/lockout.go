@@ -0,0 +1,165 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	mongo "github.com/Aran404/Goauth/internal/database/mongo"
+	log "github.com/Aran404/Goauth/internal/logger"
+	types "github.com/Aran404/Goauth/internal/types"
+)
+
+// loginSubject is something failed logins are counted against, either a username or an IP.
+type loginSubject struct {
+	key   string
+	limit int64
+}
+
+func loginSubjects(username, ip string) []loginSubject {
+	return []loginSubject{
+		{"user:" + strings.ToLower(username), types.Cfg.Security.LoginAttempts},
+		{"ip:" + ip, types.Cfg.Security.IPLoginAttempts},
+	}
+}
+
+// loginAttempt is an attempt reserved against the failure counters before the credentials are checked,
+// so parallel guesses can't all pass the limit before the first failure is written.
+type loginAttempt struct {
+	s        *Server
+	ip       string
+	subjects []loginSubject
+	counts   []int64
+}
+
+// beginAttempt refuses an attempt while the username or the IP is locked out or still waiting after a failure, otherwise it counts it.
+// Unknown usernames are counted the same way, so a lockout says nothing about whether an account exists.
+func (s *Server) beginAttempt(username, ip string) (*loginAttempt, error) {
+	subjects := loginSubjects(username, ip)
+	for _, subject := range subjects {
+		for _, prefix := range []string{"lockout:", "login_wait:"} {
+			exists, err := s.rdb.Exists(s.dbCtx, prefix+subject.key)
+			if err != nil {
+				return nil, err
+			}
+
+			if exists {
+				return nil, types.ErrorTooManyAttempts
+			}
+		}
+	}
+
+	window := time.Duration(max(types.Cfg.Security.LoginWindow, 60)) * time.Second
+	attempt := &loginAttempt{s: s, ip: ip}
+	for _, subject := range subjects {
+		if subject.limit <= 0 {
+			continue
+		}
+
+		count, err := s.rdb.Incr(s.dbCtx, "login_fail:"+subject.key, window)
+		if err != nil {
+			attempt.release()
+			return nil, err
+		}
+		attempt.subjects = append(attempt.subjects, subject)
+		attempt.counts = append(attempt.counts, count)
+
+		// Attempts still running already use up what is left of the limit
+		if count > subject.limit {
+			attempt.release()
+			return nil, types.ErrorTooManyAttempts
+		}
+	}
+
+	return attempt, nil
+}
+
+// release takes the attempt back, for credentials that were right or a check that couldn't run.
+func (a *loginAttempt) release() {
+	for _, subject := range a.subjects {
+		if _, err := a.s.rdb.Decr(a.s.dbCtx, "login_fail:"+subject.key); err != nil {
+			log.Error(log.GetStackTrace(), "Could not release login attempt, Error: %v", err.Error())
+		}
+	}
+}
+
+// failed keeps the attempt counted, each failure doubles the wait before the next try until the limit locks the subject out.
+func (a *loginAttempt) failed() {
+	lockout := time.Duration(max(types.Cfg.Security.LockoutDuration, 60)) * time.Second
+
+	for i, subject := range a.subjects {
+		count := a.counts[i]
+		if count >= subject.limit {
+			if err := a.s.rdb.Set(a.s.dbCtx, "lockout:"+subject.key, count, lockout); err != nil {
+				log.Error(log.GetStackTrace(), "Could not lock out %v, Error: %v", subject.key, err.Error())
+				continue
+			}
+
+			a.s.rdb.Delete(a.s.dbCtx, "login_fail:"+subject.key)
+			log.Warn(log.GetStackTrace(), "Locked out %v for %v after %v failed logins, IP: %v", subject.key, lockout, count, a.ip)
+			a.s.audit(nil, mongo.AuditLoginLocked, nil, map[string]string{"subject": subject.key, "ip": a.ip, "attempts": strconv.FormatInt(count, 10)})
+			continue
+		}
+
+		delay := time.Duration(types.Cfg.Security.LoginDelay) * time.Second << min(count-1, 20)
+		if delay <= 0 {
+			continue
+		}
+
+		if err := a.s.rdb.Set(a.s.dbCtx, "login_wait:"+subject.key, count, min(delay, lockout)); err != nil {
+			log.Error(log.GetStackTrace(), "Could not delay logins for %v, Error: %v", subject.key, err.Error())
+		}
+	}
+}
+
+// checkPasswordAttempt checks the password of a signed in user, wrong guesses count towards the same lockout as logins.
+func (s *Server) checkPasswordAttempt(user *mongo.UserObject, password, ip string) error {
+	attempt, err := s.beginAttempt(user.Username, ip)
+	if err != nil {
+		return err
+	}
+
+	ok, err := s.passwords.Check(password, user.Password)
+	if err != nil {
+		attempt.release()
+		return err
+	}
+
+	if !ok {
+		attempt.failed()
+		return types.ErrorIncorrectPassword
+	}
+
+	attempt.release()
+	return nil
+}
+
+// checkCodeAttempt is checkTwoFactorCode with wrong codes counted towards the lockout.
+func (s *Server) checkCodeAttempt(user *mongo.UserObject, code, ip string) error {
+	attempt, err := s.beginAttempt(user.Username, ip)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkTwoFactorCode(user, code); err != nil {
+		if err == types.ErrorInvalidCode {
+			attempt.failed()
+		} else {
+			attempt.release()
+		}
+		return err
+	}
+
+	attempt.release()
+	return nil
+}
+
+// loginSucceeded clears the failures of a username, the IP keeps its count so one valid account can't reset it.
+func (s *Server) loginSucceeded(username string) {
+	key := "user:" + strings.ToLower(username)
+	for _, prefix := range []string{"login_fail:", "login_wait:"} {
+		if err := s.rdb.Delete(s.dbCtx, prefix+key); err != nil {
+			log.Error(log.GetStackTrace(), "Could not clear failed logins, Error: %v", err.Error())
+		}
+	}
+}
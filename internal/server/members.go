@@ -0,0 +1,286 @@
+package server
+
+import (
+	"time"
+
+	crypto "github.com/Aran404/Goauth/internal/crypto"
+	mongo "github.com/Aran404/Goauth/internal/database/mongo"
+	types "github.com/Aran404/Goauth/internal/types"
+	utils "github.com/Aran404/Goauth/internal/utils"
+	"github.com/gofiber/fiber/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// InviteMember creates an invitation to join an owner, the token is only returned once
+func (s *Server) InviteMember(c fiber.Ctx) error {
+	var msg InviteMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	if !utils.ArrayContains(memberRoles, msg.Role) {
+		return types.ErrorInvalidRole
+	}
+
+	owner, err := s.getOwner(msg.OwnerID)
+	if err != nil {
+		return err
+	}
+
+	appIDs := []primitive.ObjectID{}
+	for _, v := range msg.AppIDs {
+		id, err := primitive.ObjectIDFromHex(v)
+		if err != nil || !mongo.CheckObjectArray(&owner.Applications, id) {
+			return types.ErrorInvalidApp
+		}
+		appIDs = append(appIDs, id)
+	}
+
+	// Members limited to some applications can only invite to those
+	if err := s.verifyUser(c, owner, appIDs...); err != nil {
+		return err
+	}
+
+	fields, err := s.parseJWTFields(c)
+	if err != nil {
+		return err
+	}
+
+	inviter, err := s.getUser(fields.UserID)
+	if err != nil {
+		return err
+	}
+
+	token, err := crypto.GenerateAPIKey(32)
+	if err != nil {
+		return err
+	}
+
+	invitation := &mongo.InvitationObject{
+		OwnerID:   owner.ID,
+		Token:     crypto.KeyedHash(token, s.licensePepper),
+		Role:      msg.Role,
+		AppIDs:    appIDs,
+		InvitedBy: inviter.ID,
+		ExpiresAt: time.Now().Unix() + types.Cfg.Security.InviteExpiry,
+	}
+
+	item, err := s.db.CreateAndReturn(s.dbCtx, mongo.Invitations, invitation)
+	if err != nil {
+		return err
+	}
+
+	id, ok := item.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return fiber.ErrInternalServerError
+	}
+
+	returnDump := fiber.Map{
+		"success":    true,
+		"id":         id.Hex(),
+		"token":      token,
+		"expires_at": invitation.ExpiresAt,
+		"context":    time.Now().Unix() + int64(types.Cfg.Security.AllowedContext),
+	}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// AcceptInvitation adds the signed in user to the owner that invited them
+func (s *Server) AcceptInvitation(c fiber.Ctx) error {
+	var msg InvitationMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	fields, err := s.parseJWTFields(c)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.getUser(fields.UserID)
+	if err != nil {
+		return err
+	}
+
+	query := bson.M{"token": crypto.KeyedHash(msg.Token, s.licensePepper), "expires_at": bson.M{"$gte": time.Now().Unix()}}
+	unparsed, err := s.db.Filter(s.dbCtx, mongo.Invitations, query, false, types.ErrorInvalidInvite)
+	if err != nil {
+		return err
+	}
+
+	var invitation mongo.InvitationObject
+	if err := mongo.ReadInto[mongo.InvitationObject](unparsed, &invitation); err != nil {
+		return err
+	}
+
+	// Deleting first makes the token single use even if it is accepted twice at once
+	if err := s.db.Delete(s.dbCtx, mongo.Invitations, bson.M{"_id": invitation.ID}); err != nil {
+		if err == types.ErrorNoMatches {
+			return types.ErrorInvalidInvite
+		}
+		return err
+	}
+
+	member := mongo.MemberObject{
+		UserID:  user.ID,
+		Role:    invitation.Role,
+		AppIDs:  invitation.AppIDs,
+		AddedAt: time.Now().Unix(),
+	}
+
+	ownerQuery := bson.M{"_id": invitation.OwnerID, "user_id": bson.M{"$ne": user.ID}, "members.user_id": bson.M{"$ne": user.ID}}
+	matched, err := s.db.Modify(s.dbCtx, mongo.Owners, ownerQuery, bson.M{"$push": bson.M{"members": member}})
+	if err != nil {
+		return err
+	}
+
+	if matched == 0 {
+		return types.ErrorIsMember
+	}
+
+	returnDump := fiber.Map{"success": true, "owner_id": invitation.OwnerID.Hex(), "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// ListMembers returns the users of an owner along with the invitations that haven't been accepted yet
+func (s *Server) ListMembers(c fiber.Ctx) error {
+	var msg OwnerMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	owner, err := s.getOwner(msg.OwnerID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.verifyUser(c, owner); err != nil {
+		return err
+	}
+
+	userIDs := []primitive.ObjectID{owner.User}
+	for _, v := range owner.Members {
+		userIDs = append(userIDs, v.UserID)
+	}
+
+	unparsed, err := s.db.Find(s.dbCtx, mongo.Users, bson.M{"_id": bson.M{"$in": userIDs}})
+	if err != nil {
+		return err
+	}
+
+	var users []mongo.UserObject
+	if err := mongo.ReadAllInto[mongo.UserObject](unparsed, &users); err != nil {
+		return err
+	}
+
+	usernames := make(map[primitive.ObjectID]string)
+	for _, v := range users {
+		usernames[v.ID] = v.Username
+	}
+
+	members := []fiber.Map{{"user_id": owner.User.Hex(), "username": usernames[owner.User], "role": mongo.RoleOwner, "primary": true}}
+	for _, v := range owner.Members {
+		members = append(members, fiber.Map{
+			"user_id":  v.UserID.Hex(),
+			"username": usernames[v.UserID],
+			"role":     v.Role,
+			"app_ids":  v.AppIDs,
+			"added_at": v.AddedAt,
+		})
+	}
+
+	unparsed, err = s.db.Find(s.dbCtx, mongo.Invitations, bson.M{"owner_id": owner.ID, "expires_at": bson.M{"$gte": time.Now().Unix()}})
+	if err != nil {
+		return err
+	}
+
+	var invitations []mongo.InvitationObject
+	if err := mongo.ReadAllInto[mongo.InvitationObject](unparsed, &invitations); err != nil {
+		return err
+	}
+
+	pending := []fiber.Map{}
+	for _, v := range invitations {
+		pending = append(pending, fiber.Map{"id": v.ID.Hex(), "role": v.Role, "app_ids": v.AppIDs, "expires_at": v.ExpiresAt})
+	}
+
+	returnDump := fiber.Map{
+		"success":     true,
+		"members":     members,
+		"invitations": pending,
+		"context":     time.Now().Unix() + int64(types.Cfg.Security.AllowedContext),
+	}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// RemoveMember takes away a member's access, the owner's own user can't be removed
+func (s *Server) RemoveMember(c fiber.Ctx) error {
+	var msg MemberMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	owner, err := s.getOwner(msg.OwnerID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.verifyUser(c, owner); err != nil {
+		return err
+	}
+
+	userID, err := primitive.ObjectIDFromHex(msg.UserID)
+	if err != nil {
+		return types.ErrorInvalidUserID
+	}
+
+	query := bson.M{"_id": owner.ID, "members.user_id": userID}
+	matched, err := s.db.Modify(s.dbCtx, mongo.Owners, query, bson.M{"$pull": bson.M{"members": bson.M{"user_id": userID}}})
+	if err != nil {
+		return err
+	}
+
+	if matched == 0 {
+		return types.ErrorInvalidUserID
+	}
+
+	returnDump := fiber.Map{"success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+func (s *Server) RevokeInvitation(c fiber.Ctx) error {
+	var msg InvitationIDMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	owner, err := s.getOwner(msg.OwnerID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.verifyUser(c, owner); err != nil {
+		return err
+	}
+
+	invitationID, err := primitive.ObjectIDFromHex(msg.InvitationID)
+	if err != nil {
+		return types.ErrorInvalidInvite
+	}
+
+	if err := s.db.Delete(s.dbCtx, mongo.Invitations, bson.M{"_id": invitationID, "owner_id": owner.ID}); err != nil {
+		if err == types.ErrorNoMatches {
+			return types.ErrorInvalidInvite
+		}
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	crypto "github.com/Aran404/Goauth/internal/crypto"
+	mongo "github.com/Aran404/Goauth/internal/database/mongo"
+	types "github.com/Aran404/Goauth/internal/types"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// HashLicenseKeys hashes every license key that is still stored in plain text.
+// It is safe to run more than once, already hashed keys are skipped.
+func HashLicenseKeys(ctx context.Context, db *mongo.Connection, pepper []byte) (int, error) {
+	unparsed, err := db.Filter(ctx, mongo.Licenses, bson.M{"hashed": bson.M{"$ne": true}}, false)
+	if err == types.ErrorNotFound {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	var licenses []mongo.LicenseObject
+	if err := mongo.ReadAllInto[mongo.LicenseObject](unparsed, &licenses); err != nil {
+		return 0, err
+	}
+
+	for i, l := range licenses {
+		update := bson.M{
+			"key":    crypto.KeyedHash(l.Key, pepper),
+			"hashed": true,
+			"hint":   licenseHint(l.Key),
+		}
+
+		// The plain text filter guards against another migration hashing the key twice
+		if err := db.Update(ctx, mongo.Licenses, bson.M{"_id": l.ID, "key": l.Key}, update); err != nil {
+			return i, err
+		}
+	}
+
+	return len(licenses), nil
+}
+
+// MoveIntegrityPins moves the integrity signature applications were pinned to before the build registry existed into their builds.
+// It is safe to run more than once, applications without a pin are skipped.
+func MoveIntegrityPins(ctx context.Context, db *mongo.Connection) (int, error) {
+	unparsed, err := db.Filter(ctx, mongo.Applications, bson.M{"integrity_signature": bson.M{"$type": "string"}}, false)
+	if err == types.ErrorNotFound {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	var apps []mongo.ApplicationObject
+	if err := mongo.ReadAllInto[mongo.ApplicationObject](unparsed, &apps); err != nil {
+		return 0, err
+	}
+
+	for i := range apps {
+		if err := moveIntegrityPin(ctx, db, &apps[i]); err != nil {
+			return i, err
+		}
+	}
+
+	return len(apps), nil
+}
+
+// moveIntegrityPin registers an application's legacy pin as a build, lowercased like every other hash,
+// so owners can deprecate or block it. The pin is removed in the same update.
+func moveIntegrityPin(ctx context.Context, db *mongo.Connection, app *mongo.ApplicationObject) error {
+	pin := strings.ToLower(*app.IntegritySignature)
+	build := mongo.BuildObject{
+		Version:   "legacy-pin",
+		Hash:      pin,
+		Status:    mongo.BuildActive,
+		CreatedAt: time.Now().Unix(),
+	}
+
+	// The pin filter makes sure only one caller moves it, a build already registered with the hash is kept as is
+	query := bson.M{"_id": app.ID, "integrity_signature": *app.IntegritySignature, "builds.hash": bson.M{"$ne": pin}}
+	update := bson.M{"$push": bson.M{"builds": build}, "$unset": bson.M{"integrity_signature": ""}}
+	matched, err := db.Modify(ctx, mongo.Applications, query, update)
+	if err != nil {
+		return err
+	}
+
+	if matched == 0 {
+		delete(query, "builds.hash")
+		if _, err := db.Modify(ctx, mongo.Applications, query, bson.M{"$unset": bson.M{"integrity_signature": ""}}); err != nil {
+			return err
+		}
+	}
+
+	if app.FindBuild(pin) == nil {
+		app.Builds = append(app.Builds, build)
+	}
+	app.IntegritySignature = nil
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"time"
+
+	crypto "github.com/Aran404/Goauth/internal/crypto"
+	mongo "github.com/Aran404/Goauth/internal/database/mongo"
+	log "github.com/Aran404/Goauth/internal/logger"
+	types "github.com/Aran404/Goauth/internal/types"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// newPasswords builds the password hasher from the config, an unknown algorithm stops the server.
+func newPasswords() (*crypto.Passwords, string) {
+	cfg := types.Cfg.PasswordHashing
+	hasher, err := crypto.NewPasswordHasher(cfg.Algorithm, cfg.Memory, cfg.Iterations, cfg.Parallelism, cfg.BcryptCost)
+	if err != nil {
+		log.Fatal(log.GetStackTrace(), err.Error())
+	}
+
+	// Compared against when a username doesn't exist, so a miss takes as long as a wrong password
+	dummyHash, err := hasher.Hash("goauth-dummy-password")
+	if err != nil {
+		log.Fatal(log.GetStackTrace(), err.Error())
+	}
+
+	return crypto.NewPasswords(hasher, cfg.Workers, time.Duration(cfg.QueueTimeout)*time.Second), dummyHash
+}
+
+// rehashPassword replaces an outdated hash after a successful login, a failure only means it is tried again next time.
+func (s *Server) rehashPassword(user *mongo.UserObject, password string) {
+	hashed, err := s.passwords.Hash(password)
+	if err != nil {
+		log.Error(log.GetStackTrace(), "Could not rehash password, Error: %v", err.Error())
+		return
+	}
+
+	query := bson.M{"_id": user.ID, "password": user.Password}
+	if _, err := s.db.Modify(s.dbCtx, mongo.Users, query, bson.M{"$set": bson.M{"password": hashed}}); err != nil {
+		log.Error(log.GetStackTrace(), "Could not rehash password, Error: %v", err.Error())
+		return
+	}
+	user.Password = hashed
+}
@@ -0,0 +1,248 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	mongo "github.com/Aran404/Goauth/internal/database/mongo"
+	types "github.com/Aran404/Goauth/internal/types"
+	utils "github.com/Aran404/Goauth/internal/utils"
+	"github.com/gofiber/fiber/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// reserveApp adds an application to the owner before it is created.
+// The limit is part of the update query so concurrent requests can't go over the plan.
+func (s *Server) reserveApp(owner *mongo.OwnerObject, appID primitive.ObjectID) error {
+	query := bson.M{"_id": owner.ID}
+	if limit := owner.Plan.MaxApplications; limit > 0 {
+		query[fmt.Sprintf("app_ids.%d", limit-1)] = bson.M{"$exists": false}
+	}
+
+	matched, err := s.db.Modify(s.dbCtx, mongo.Owners, query, bson.M{"$addToSet": bson.M{"app_ids": appID}})
+	if err != nil {
+		return err
+	}
+
+	if matched == 0 {
+		return types.ErrorPlanApplications
+	}
+
+	return nil
+}
+
+func (s *Server) releaseApp(ownerID, appID primitive.ObjectID) error {
+	_, err := s.db.Modify(s.dbCtx, mongo.Owners, bson.M{"_id": ownerID}, bson.M{"$pull": bson.M{"app_ids": appID}})
+	return err
+}
+
+func (s *Server) countLicenses(owner *mongo.OwnerObject) (int64, error) {
+	return s.db.Count(s.dbCtx, mongo.Licenses, bson.M{"app_id": bson.M{"$in": owner.Applications}})
+}
+
+// reserveLicenses counts licenses against the owner's plan before they are created, archived licenses don't count.
+// Like reserveApp the limit is checked by the update itself.
+func (s *Server) reserveLicenses(owner *mongo.OwnerObject, n int64) error {
+	if owner.LicenseCount == nil {
+		count, err := s.countLicenses(owner)
+		if err != nil {
+			return err
+		}
+
+		// Owners created before the counter existed start from what they have, only the first request sets it
+		query := bson.M{"_id": owner.ID, "license_count": bson.M{"$exists": false}}
+		if _, err := s.db.Modify(s.dbCtx, mongo.Owners, query, bson.M{"$set": bson.M{"license_count": count}}); err != nil {
+			return err
+		}
+	}
+
+	query := bson.M{"_id": owner.ID}
+	if limit := owner.Plan.MaxLicenses; limit > 0 {
+		query["license_count"] = bson.M{"$lte": limit - n}
+	}
+
+	matched, err := s.db.Modify(s.dbCtx, mongo.Owners, query, bson.M{"$inc": bson.M{"license_count": n}})
+	if err != nil {
+		return err
+	}
+
+	if matched == 0 {
+		return types.ErrorPlanLicenses
+	}
+
+	return nil
+}
+
+// releaseLicenses gives back what deleted licenses, or ones that were never created, had counted.
+func (s *Server) releaseLicenses(ownerID primitive.ObjectID, n int64) error {
+	query := bson.M{"_id": ownerID, "license_count": bson.M{"$exists": true}}
+	_, err := s.db.Modify(s.dbCtx, mongo.Owners, query, bson.M{"$inc": bson.M{"license_count": -n}})
+	return err
+}
+
+// countActiveDevices sums the devices bound to the owner's licenses that are neither revoked nor expired.
+// Licenses activated before device limits existed count their fingerprint, the same way claimDevice treats them.
+func (s *Server) countActiveDevices(owner *mongo.OwnerObject) (int64, error) {
+	bound := bson.M{"$cond": bson.A{
+		bson.M{"$gt": bson.A{bson.M{"$size": bson.M{"$ifNull": bson.A{"$devices", bson.A{}}}}, 0}},
+		bson.M{"$size": "$devices"},
+		bson.M{"$cond": bson.A{bson.M{"$gt": bson.A{"$fingerprint", nil}}, 1, 0}},
+	}}
+
+	pipeline := []bson.M{
+		{"$match": bson.M{
+			"app_id":  bson.M{"$in": owner.Applications},
+			"revoked": bson.M{"$ne": true},
+			"expiry":  bson.M{"$gt": time.Now().Unix()},
+		}},
+		{"$group": bson.M{
+			"_id":     nil,
+			"devices": bson.M{"$sum": bound},
+		}},
+	}
+
+	result, err := s.db.Aggregate(s.dbCtx, mongo.Licenses, pipeline)
+	if err != nil || len(result) == 0 {
+		return 0, err
+	}
+
+	switch v := result[0]["devices"].(type) {
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	}
+
+	return 0, nil
+}
+
+// claimDevice is run before a license binds a device it hasn't seen yet, claiming a slot of the owner's active devices.
+// Like reserveLicenses the limit is checked by the update itself. Devices of licenses that expired or were revoked
+// are only given back by a recount, which happens once the counter is found full.
+// It reports whether a slot was claimed, which has to be released again if the device is never bound.
+func (s *Server) claimDevice(owner *mongo.OwnerObject, l *mongo.LicenseObject, policy *mongo.PolicyObject, fingerprint string) (bool, error) {
+	limit := owner.Plan.MaxActiveDevices
+	if limit <= 0 {
+		return false, nil
+	}
+
+	// Licenses activated before device limits existed are already bound to their first fingerprint
+	bound := l.Devices
+	if len(bound) == 0 && l.Fingerprint != nil {
+		bound = []string{*l.Fingerprint}
+	}
+
+	if len(bound) > 0 && (!policy.EnforceFingerprint || utils.ArrayContains(bound, fingerprint)) {
+		return false, nil
+	}
+
+	for recounted := false; ; recounted = true {
+		query := bson.M{"_id": owner.ID, "active_devices": bson.M{"$lt": limit}}
+		matched, err := s.db.Modify(s.dbCtx, mongo.Owners, query, bson.M{"$inc": bson.M{"active_devices": 1}})
+		if err != nil {
+			return false, err
+		}
+
+		if matched > 0 {
+			return true, nil
+		}
+
+		if recounted {
+			return false, types.ErrorPlanDevices
+		}
+
+		count, err := s.countActiveDevices(owner)
+		if err != nil {
+			return false, err
+		}
+
+		if count >= limit {
+			return false, types.ErrorPlanDevices
+		}
+
+		// Only a counter that is still full or missing is replaced, so a concurrent recount isn't counted twice
+		full := bson.M{"_id": owner.ID, "active_devices": bson.M{"$not": bson.M{"$lt": limit}}}
+		if _, err := s.db.Modify(s.dbCtx, mongo.Owners, full, bson.M{"$set": bson.M{"active_devices": count}}); err != nil {
+			return false, err
+		}
+	}
+}
+
+// releaseDevice gives back a slot claimed for a device that was never bound.
+func (s *Server) releaseDevice(ownerID primitive.ObjectID) error {
+	query := bson.M{"_id": ownerID, "active_devices": bson.M{"$gt": 0}}
+	_, err := s.db.Modify(s.dbCtx, mongo.Owners, query, bson.M{"$inc": bson.M{"active_devices": -1}})
+	return err
+}
+
+// SetOwnerPlan changes the limits of an owner, anything already over a new limit is kept but nothing more can be added
+func (s *Server) SetOwnerPlan(c fiber.Ctx) error {
+	var msg PlanMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	if msg.MaxApplications < 0 || msg.MaxLicenses < 0 || msg.MaxActiveDevices < 0 {
+		return types.ErrorInvalidPlan
+	}
+
+	owner, err := s.getOwner(msg.OwnerID)
+	if err != nil {
+		return err
+	}
+
+	plan := mongo.PlanObject{
+		MaxApplications:  msg.MaxApplications,
+		MaxLicenses:      msg.MaxLicenses,
+		MaxActiveDevices: msg.MaxActiveDevices,
+	}
+
+	if err := s.db.Update(s.dbCtx, mongo.Owners, bson.M{"_id": owner.ID}, bson.M{"plan": plan}); err != nil {
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// GetOwnerPlan returns the limits of an owner next to what it currently uses
+func (s *Server) GetOwnerPlan(c fiber.Ctx) error {
+	var msg OwnerMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	owner, err := s.getOwner(msg.OwnerID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.verifyUser(c, owner); err != nil {
+		return err
+	}
+
+	licenses, err := s.countLicenses(owner)
+	if err != nil {
+		return err
+	}
+
+	devices, err := s.countActiveDevices(owner)
+	if err != nil {
+		return err
+	}
+
+	returnDump := fiber.Map{
+		"success": true,
+		"plan":    owner.Plan,
+		"usage": fiber.Map{
+			"applications":   len(owner.Applications),
+			"licenses":       licenses,
+			"active_devices": devices,
+		},
+		"context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext),
+	}
+	return s.EncryptJson(c, returnDump, session)
+}
@@ -0,0 +1,150 @@
+package server
+
+import (
+	"time"
+
+	mongo "github.com/Aran404/Goauth/internal/database/mongo"
+	types "github.com/Aran404/Goauth/internal/types"
+	utils "github.com/Aran404/Goauth/internal/utils"
+	"github.com/gofiber/fiber/v3"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	PermAll = "*"
+
+	PermOwnerCreate    = "owner:create"
+	PermOwnerDelete    = "owner:delete"
+	PermOwnerPlan      = "owner:plan"
+	PermUserManage     = "user:manage"
+	PermAppCreate      = "app:create"
+	PermAppRead        = "app:read"
+	PermAppManage      = "app:manage"
+	PermAppDelete      = "app:delete"
+	PermLicenseCreate  = "license:create"
+	PermLicenseRead    = "license:read"
+	PermLicenseUpdate  = "license:update"
+	PermLicenseRevoke  = "license:revoke"
+	PermLicenseDelete  = "license:delete"
+	PermWebhookRead    = "webhook:read"
+	PermWebhookManage  = "webhook:manage"
+	PermVariableRead   = "variable:read"
+	PermVariableManage = "variable:manage"
+	PermArchiveRead    = "archive:read"
+	PermArchiveRestore = "archive:restore"
+	PermMemberRead     = "member:read"
+	PermMemberManage   = "member:manage"
+	PermTokenManage    = "token:manage"
+	PermResellerRead   = "reseller:read"
+	PermResellerManage = "reseller:manage"
+	PermAuditRead      = "audit:read"
+)
+
+var (
+	permissions = []string{
+		PermOwnerCreate, PermOwnerDelete, PermOwnerPlan, PermUserManage,
+		PermAppCreate, PermAppRead, PermAppManage, PermAppDelete,
+		PermLicenseCreate, PermLicenseRead, PermLicenseUpdate, PermLicenseRevoke, PermLicenseDelete,
+		PermWebhookRead, PermWebhookManage, PermVariableRead, PermVariableManage,
+		PermArchiveRead, PermArchiveRestore, PermMemberRead, PermMemberManage, PermTokenManage,
+		PermResellerRead, PermResellerManage, PermAuditRead,
+	}
+
+	readOnly = []string{PermAppRead, PermLicenseRead, PermWebhookRead, PermVariableRead, PermArchiveRead, PermMemberRead, PermResellerRead}
+
+	rolePermissions = map[string][]string{
+		mongo.RoleAdmin:    {PermAll},
+		mongo.RoleSupport:  append([]string{PermLicenseUpdate, PermLicenseRevoke}, readOnly...),
+		mongo.RoleAuditor:  append([]string{PermAuditRead}, readOnly...),
+		mongo.RoleReseller: {PermLicenseRead},
+		mongo.RoleOwner: {
+			PermAppCreate, PermAppRead, PermAppManage, PermAppDelete,
+			PermLicenseCreate, PermLicenseRead, PermLicenseUpdate, PermLicenseRevoke, PermLicenseDelete,
+			PermWebhookRead, PermWebhookManage, PermVariableRead, PermVariableManage,
+			PermArchiveRead, PermArchiveRestore, PermMemberRead, PermMemberManage, PermTokenManage,
+			PermResellerRead, PermResellerManage,
+		},
+	}
+
+	// Staff roles act on every owner, the rest only on the owners they belong to
+	globalRoles = []string{mongo.RoleAdmin, mongo.RoleSupport, mongo.RoleAuditor}
+
+	// Roles an owner can invite members with, resellers are created with /create-reseller so they only sell within their quotas
+	memberRoles = []string{mongo.RoleOwner, mongo.RoleSupport, mongo.RoleAuditor}
+)
+
+// Permissions returns what a user may do, the role's permissions plus any granted to the user directly.
+func Permissions(u *mongo.UserObject) []string {
+	granted := append([]string{}, rolePermissions[u.GetRole()]...)
+	for _, v := range u.Permissions {
+		if !utils.ArrayContains(granted, v) {
+			granted = append(granted, v)
+		}
+	}
+
+	return granted
+}
+
+// Can checks a permission against the claims of the access token
+func (u *UserJWT) Can(permission string) bool {
+	return permission == "" || utils.ArrayContains(u.Permissions, PermAll) || utils.ArrayContains(u.Permissions, permission)
+}
+
+// Global reports whether the user's role applies to every owner
+func (u *UserJWT) Global() bool {
+	return utils.ArrayContains(globalRoles, u.Role)
+}
+
+// currentClaims replaces the role and permissions of an access token with the account's current ones.
+// The token in the context is updated too, so a role change applies to the next request instead of the next refresh.
+func currentClaims(c fiber.Ctx, fields *UserJWT, user *mongo.UserObject) {
+	fields.Role = user.GetRole()
+	fields.Permissions = Permissions(user)
+
+	if token, ok := c.Locals("user").(*jwt.Token); ok {
+		if claims, ok := token.Claims.(jwt.MapClaims); ok {
+			claims["role"] = fields.Role
+			claims["permissions"] = fields.Permissions
+		}
+	}
+}
+
+// requirePermission is the route level check declared by Route.Permission.
+// The permission is kept in the context so verifyUser knows what the request is trying to do.
+func (s *Server) requirePermission(permission string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		fields, err := s.parseJWTFields(c)
+		if err != nil {
+			return err
+		}
+
+		if time.Now().Unix() > int64(fields.Exp) {
+			return fiber.ErrUnauthorized
+		}
+
+		if fields.OwnerID == "" {
+			user, err := s.checkAccount(fields)
+			if err != nil {
+				return err
+			}
+			currentClaims(c, fields, user)
+		}
+
+		if !fields.Can(permission) {
+			return types.ErrorForbidden
+		}
+
+		// API tokens only reach routes that act on their owner
+		if fields.OwnerID != "" && permission == "" {
+			return types.ErrorForbidden
+		}
+
+		// While two-factor is enforced, accounts without it may only set it up
+		if types.Cfg.Security.EnforceTwoFactor && fields.OwnerID == "" && !fields.TwoFactor && !utils.ArrayContains(twoFactorSetup, c.Path()) {
+			return types.ErrorTwoFactorRequired
+		}
+
+		c.Locals("permission", permission)
+		return c.Next()
+	}
+}
@@ -0,0 +1,460 @@
+package server
+
+import (
+	"time"
+
+	mongo "github.com/Aran404/Goauth/internal/database/mongo"
+	types "github.com/Aran404/Goauth/internal/types"
+	utils "github.com/Aran404/Goauth/internal/utils"
+	"github.com/gofiber/fiber/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// parseQuotas turns the requested quotas into quota objects, carrying over what was already used.
+func parseQuotas(owner *mongo.OwnerObject, quotas []QuotaMsg, previous []mongo.QuotaObject) ([]mongo.QuotaObject, error) {
+	used := make(map[primitive.ObjectID]int64)
+	for _, v := range previous {
+		used[v.AppID] = v.Used
+	}
+
+	parsed := []mongo.QuotaObject{}
+	for _, v := range quotas {
+		appID, err := primitive.ObjectIDFromHex(v.AppID)
+		if err != nil || !mongo.CheckObjectArray(&owner.Applications, appID) {
+			return nil, types.ErrorInvalidApp
+		}
+
+		if v.Limit < 0 {
+			return nil, types.ErrorInvalidTemplate
+		}
+
+		parsed = append(parsed, mongo.QuotaObject{
+			AppID:     appID,
+			Limit:     v.Limit,
+			Used:      used[appID],
+			Remaining: max(v.Limit-used[appID], 0),
+		})
+	}
+
+	return parsed, nil
+}
+
+func validTemplates(templates []mongo.TemplateObject) bool {
+	names := []string{}
+	for _, v := range templates {
+		if v.Name == "" || v.Expiry == 0 || utils.ArrayContains(names, v.Name) {
+			return false
+		}
+		names = append(names, v.Name)
+	}
+
+	return true
+}
+
+func (s *Server) getReseller(query bson.M) (*mongo.ResellerObject, error) {
+	unparsed, err := s.db.Filter(s.dbCtx, mongo.Resellers, query, false, types.ErrorInvalidReseller)
+	if err != nil {
+		return nil, err
+	}
+
+	var reseller mongo.ResellerObject
+	if err := mongo.ReadInto[mongo.ResellerObject](unparsed, &reseller); err != nil {
+		return nil, err
+	}
+
+	return &reseller, nil
+}
+
+// CreateReseller links a user to an owner as a reseller with per-app quotas and the templates they may sell
+func (s *Server) CreateReseller(c fiber.Ctx) error {
+	var msg ResellerMsg
+	session, err := s.parseAppBody(c, &msg, "ResellerID")
+	if err != nil {
+		return err
+	}
+
+	if !validTemplates(msg.Templates) {
+		return types.ErrorInvalidTemplate
+	}
+
+	owner, err := s.getOwner(msg.OwnerID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.verifyUser(c, owner); err != nil {
+		return err
+	}
+
+	userID, err := primitive.ObjectIDFromHex(msg.UserID)
+	if err != nil {
+		return types.ErrorInvalidUserID
+	}
+
+	exists, err := s.db.Exists(s.dbCtx, mongo.Users, bson.M{"_id": userID})
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return types.ErrorInvalidUserID
+	}
+
+	quotas, err := parseQuotas(owner, msg.Quotas, nil)
+	if err != nil {
+		return err
+	}
+
+	if msg.Templates == nil {
+		msg.Templates = []mongo.TemplateObject{}
+	}
+
+	reseller := &mongo.ResellerObject{
+		OwnerID:   owner.ID,
+		UserID:    userID,
+		Name:      msg.Name,
+		Quotas:    quotas,
+		Templates: msg.Templates,
+		CreatedAt: time.Now().Unix(),
+	}
+
+	// A user resells for an owner at most once
+	exists, err = s.db.Exists(s.dbCtx, mongo.Resellers, bson.M{"owner_id": owner.ID, "user_id": userID})
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return types.WithMessage(types.ErrorInvalidReseller, "This user is already a reseller of the owner.")
+	}
+
+	item, err := s.db.CreateAndReturn(s.dbCtx, mongo.Resellers, reseller)
+	if err != nil {
+		return err
+	}
+
+	id, ok := item.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return fiber.ErrInternalServerError
+	}
+
+	returnDump := fiber.Map{"success": true, "id": id.Hex(), "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// UpdateReseller replaces the quotas and templates of a reseller, usage so far is kept
+func (s *Server) UpdateReseller(c fiber.Ctx) error {
+	var msg ResellerMsg
+	session, err := s.parseAppBody(c, &msg, "UserID", "Name")
+	if err != nil {
+		return err
+	}
+
+	owner, err := s.getOwner(msg.OwnerID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.verifyUser(c, owner); err != nil {
+		return err
+	}
+
+	resellerID, err := primitive.ObjectIDFromHex(msg.ResellerID)
+	if err != nil {
+		return types.ErrorInvalidReseller
+	}
+
+	reseller, err := s.getReseller(bson.M{"_id": resellerID, "owner_id": owner.ID})
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{}
+	if msg.Name != "" {
+		update["name"] = msg.Name
+	}
+
+	if msg.Quotas != nil {
+		quotas, err := parseQuotas(owner, msg.Quotas, reseller.Quotas)
+		if err != nil {
+			return err
+		}
+		update["quotas"] = quotas
+	}
+
+	if msg.Templates != nil {
+		if !validTemplates(msg.Templates) {
+			return types.ErrorInvalidTemplate
+		}
+		update["templates"] = msg.Templates
+	}
+
+	if msg.Disabled != nil {
+		update["disabled"] = *msg.Disabled
+	}
+
+	if len(update) == 0 {
+		return types.ErrorEmptyFields
+	}
+
+	if err := s.db.Update(s.dbCtx, mongo.Resellers, bson.M{"_id": reseller.ID}, update); err != nil {
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// ListResellers returns the resellers of an owner with their usage per application
+func (s *Server) ListResellers(c fiber.Ctx) error {
+	var msg OwnerMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	owner, err := s.getOwner(msg.OwnerID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.verifyUser(c, owner); err != nil {
+		return err
+	}
+
+	unparsed, err := s.db.Find(s.dbCtx, mongo.Resellers, bson.M{"owner_id": owner.ID})
+	if err != nil {
+		return err
+	}
+
+	resellers := []mongo.ResellerObject{}
+	if err := mongo.ReadAllInto[mongo.ResellerObject](unparsed, &resellers); err != nil {
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "resellers": resellers, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// ResellerUsage reports how many licenses a reseller created per application and how many were activated
+func (s *Server) ResellerUsage(c fiber.Ctx) error {
+	var msg ResellerMsg
+	session, err := s.parseAppBody(c, &msg, "UserID", "Name")
+	if err != nil {
+		return err
+	}
+
+	owner, err := s.getOwner(msg.OwnerID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.verifyUser(c, owner); err != nil {
+		return err
+	}
+
+	resellerID, err := primitive.ObjectIDFromHex(msg.ResellerID)
+	if err != nil {
+		return types.ErrorInvalidReseller
+	}
+
+	reseller, err := s.getReseller(bson.M{"_id": resellerID, "owner_id": owner.ID})
+	if err != nil {
+		return err
+	}
+
+	usage := []fiber.Map{}
+	for _, v := range reseller.Quotas {
+		query := bson.M{"reseller_id": reseller.ID, "app_id": v.AppID}
+		created, err := s.db.Count(s.dbCtx, mongo.Licenses, query)
+		if err != nil {
+			return err
+		}
+
+		query["expiry"] = bson.M{"$ne": nil}
+		activated, err := s.db.Count(s.dbCtx, mongo.Licenses, query)
+		if err != nil {
+			return err
+		}
+
+		usage = append(usage, fiber.Map{
+			"app_id":    v.AppID.Hex(),
+			"limit":     v.Limit,
+			"used":      v.Used,
+			"remaining": v.Remaining,
+			"created":   created,
+			"activated": activated,
+		})
+	}
+
+	returnDump := fiber.Map{"success": true, "usage": usage, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+func (s *Server) DeleteReseller(c fiber.Ctx) error {
+	var msg ResellerMsg
+	session, err := s.parseAppBody(c, &msg, "UserID", "Name")
+	if err != nil {
+		return err
+	}
+
+	owner, err := s.getOwner(msg.OwnerID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.verifyUser(c, owner); err != nil {
+		return err
+	}
+
+	resellerID, err := primitive.ObjectIDFromHex(msg.ResellerID)
+	if err != nil {
+		return types.ErrorInvalidReseller
+	}
+
+	// Licenses keep their reseller ID so past sales can still be reported on
+	if err := s.db.Delete(s.dbCtx, mongo.Resellers, bson.M{"_id": resellerID, "owner_id": owner.ID}); err != nil {
+		if err == types.ErrorNoMatches {
+			return types.ErrorInvalidReseller
+		}
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// ResellerInfo shows a reseller their own quotas and templates for an owner
+func (s *Server) ResellerInfo(c fiber.Ctx) error {
+	var msg OwnerMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	reseller, err := s.currentReseller(c, msg.OwnerID)
+	if err != nil {
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "reseller": reseller, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// currentReseller finds the reseller record of the signed in user for an owner.
+func (s *Server) currentReseller(c fiber.Ctx, owner string) (*mongo.ResellerObject, error) {
+	fields, err := s.parseJWTFields(c)
+	if err != nil {
+		return nil, err
+	}
+
+	// API tokens belong to owners, not resellers
+	if fields.OwnerID != "" {
+		return nil, types.ErrorForbidden
+	}
+
+	userID, err := primitive.ObjectIDFromHex(fields.UserID)
+	if err != nil {
+		return nil, types.ErrorInvalidUserID
+	}
+
+	ownerID, err := primitive.ObjectIDFromHex(owner)
+	if err != nil {
+		return nil, types.ErrorInvalidOwner
+	}
+
+	return s.getReseller(bson.M{"owner_id": ownerID, "user_id": userID, "disabled": bson.M{"$ne": true}})
+}
+
+// ResellerCreateLicense creates a license from one of the reseller's templates, using up one of their quota.
+// The reseller record is what authorizes it, the route needs no permission of its own.
+func (s *Server) ResellerCreateLicense(c fiber.Ctx) error {
+	var msg ResellerLicenseMsg
+	session, err := s.parseAppBody(c, &msg, "Notes")
+	if err != nil {
+		return err
+	}
+
+	if !validMetadata(msg.Metadata) {
+		return types.ErrorInvalidMetadata
+	}
+
+	reseller, err := s.currentReseller(c, msg.OwnerID)
+	if err != nil {
+		return err
+	}
+
+	var template *mongo.TemplateObject
+	for i := range reseller.Templates {
+		if reseller.Templates[i].Name == msg.Template {
+			template = &reseller.Templates[i]
+		}
+	}
+
+	if template == nil {
+		return types.ErrorInvalidTemplate
+	}
+
+	appID, err := primitive.ObjectIDFromHex(msg.AppID)
+	if err != nil {
+		return types.ErrorInvalidApp
+	}
+
+	owner, err := s.getOwner(reseller.OwnerID.Hex())
+	if err != nil {
+		return err
+	}
+
+	// The quota is claimed in the same update that checks it, so concurrent requests can't overshoot
+	quota := bson.M{"_id": reseller.ID, "disabled": bson.M{"$ne": true}, "quotas": bson.M{"$elemMatch": bson.M{"app_id": appID, "remaining": bson.M{"$gt": 0}}}}
+	matched, err := s.db.Modify(s.dbCtx, mongo.Resellers, quota, bson.M{"$inc": bson.M{"quotas.$.remaining": -1, "quotas.$.used": 1}})
+	if err != nil {
+		return err
+	}
+
+	if matched == 0 {
+		return types.ErrorQuotaExceeded
+	}
+
+	// Give the quota back when the license is never created
+	refund := func() error {
+		query := bson.M{"_id": reseller.ID, "quotas.app_id": appID}
+		_, err := s.db.Modify(s.dbCtx, mongo.Resellers, query, bson.M{"$inc": bson.M{"quotas.$.remaining": 1, "quotas.$.used": -1}})
+		return err
+	}
+
+	// Reseller licenses count towards the owner's plan as well
+	if err := s.reserveLicenses(owner, 1); err != nil {
+		if err := refund(); err != nil {
+			return err
+		}
+		return err
+	}
+
+	license := &mongo.LicenseObject{
+		OwnerID:         reseller.OwnerID,
+		ExpectedExpiry:  template.Expiry,
+		Metadata:        msg.Metadata,
+		VisibleMetadata: msg.VisibleMetadata,
+		Notes:           msg.Notes,
+		Tier:            template.Tier,
+		CreatedBy:       reseller.UserID,
+		ResellerID:      reseller.ID,
+	}
+
+	licenseString, err := s.issueLicense(license, msg.AppID, utils.LicenseSettings{Mask: template.Mask})
+	if err != nil {
+		if err := s.releaseLicenses(owner.ID, 1); err != nil {
+			return err
+		}
+
+		if err := refund(); err != nil {
+			return err
+		}
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "key": licenseString, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
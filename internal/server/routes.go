@@ -12,6 +12,7 @@ import (
 	mongo "github.com/Aran404/Goauth/internal/database/mongo"
 	redis "github.com/Aran404/Goauth/internal/database/redis"
 	log "github.com/Aran404/Goauth/internal/logger"
+	mailer "github.com/Aran404/Goauth/internal/mailer"
 	types "github.com/Aran404/Goauth/internal/types"
 	utils "github.com/Aran404/Goauth/internal/utils"
 	"github.com/gofiber/fiber/v3"
@@ -27,11 +28,29 @@ func (s *Server) ErrorHandler(c fiber.Ctx, errResp error) error {
 		return c.Status(err.Code).JSON(fiber.Map{"error": err.Message})
 	}
 
+	// Password hashing lives in crypto, which can't depend on the error types
+	if errors.Is(errResp, crypto.ErrBusy) {
+		errResp = types.ErrorServerBusy
+	}
+
+	var (
+		message  string
+		detailed *types.DetailedError
+	)
+
+	if errors.As(errResp, &detailed) {
+		message = detailed.Message
+	}
+
 	errType := types.ErrorType(errResp)
 	errResp = errors.New(types.ProperError(errResp))
 
 	plain := func() error {
-		return c.Status(errType).JSON(fiber.Map{"error": errResp.Error(), "success": false})
+		dump := fiber.Map{"error": errResp.Error(), "success": false}
+		if message != "" {
+			dump["message"] = message
+		}
+		return c.Status(errType).JSON(dump)
 	}
 
 	sessionID := c.Get("X-Session-Id")
@@ -62,6 +81,10 @@ func (s *Server) ErrorHandler(c fiber.Ctx, errResp error) error {
 		"context": uint64(time.Now().Unix()) + uint64(types.Cfg.Security.AllowedContext),
 	}
 
+	if message != "" {
+		plainText["message"] = message
+	}
+
 	if err := s.EncryptJson(c, plainText, session); err != nil {
 		log.Error(log.GetStackTrace(), err.Error())
 		return fiber.ErrInternalServerError
@@ -70,13 +93,29 @@ func (s *Server) ErrorHandler(c fiber.Ctx, errResp error) error {
 	return nil
 }
 
-func NewServer(dbCtx context.Context, rdb *redis.Connection, jwtSecret []byte) *Server {
+func NewServer(dbCtx context.Context, rdb *redis.Connection, jwtSecret, licensePepper []byte) *Server {
+	if err := utils.CompilePolicies(&types.Cfg.PasswordPolicy, &types.Cfg.UsernamePolicy); err != nil {
+		log.Fatal(log.GetStackTrace(), err.Error())
+	}
+
+	// Existing duplicates stop an index from being created, the server still starts so they can be cleaned up
+	db := mongo.NewConn(dbCtx)
+	if err := db.EnsureIndexes(dbCtx); err != nil {
+		log.Error(log.GetStackTrace(), "%v", err.Error())
+	}
+
+	passwords, dummyHash := newPasswords()
 	return &Server{
-		rdb:     rdb,
-		smutex:  &sync.Mutex{},
-		db:      mongo.NewConn(dbCtx),
-		dbCtx:   dbCtx,
-		dbmutex: &sync.Mutex{},
+		rdb:           rdb,
+		smutex:        &sync.Mutex{},
+		db:            db,
+		dbCtx:         dbCtx,
+		dbmutex:       &sync.Mutex{},
+		jwtSecret:     jwtSecret,
+		licensePepper: licensePepper,
+		passwords:     passwords,
+		dummyHash:     dummyHash,
+		mailer:        mailer.New(),
 	}
 }
 
@@ -170,6 +209,14 @@ func (s *Server) Bind() {
 		},
 	})
 
+	// Automation may use an API token instead of logging in
+	authenticate := func(c fiber.Ctx) error {
+		if c.Get("X-Api-Token") != "" {
+			return s.apiTokenMiddleware(c)
+		}
+		return jwtMiddleware(c)
+	}
+
 	var Routes = [...]*Route{
 		{
 			Method:     "POST",
@@ -182,6 +229,7 @@ func (s *Server) Bind() {
 			Path:       "/create-owner",
 			Func:       s.NewOwner,
 			Restricted: true,
+			Permission: PermOwnerCreate,
 		},
 		{
 			Method:     "POST",
@@ -212,19 +260,456 @@ func (s *Server) Bind() {
 			Path:       "/create-application",
 			Func:       s.CreateApplication,
 			Restricted: true,
+			Permission: PermAppCreate,
 		},
 		{
 			Method:     "POST",
 			Path:       "/create-license",
 			Func:       s.CreateLicense,
 			Restricted: true,
+			Permission: PermLicenseCreate,
+		},
+		{
+			Method:     "POST",
+			Path:       "/update-license",
+			Func:       s.UpdateLicense,
+			Restricted: true,
+			Permission: PermLicenseUpdate,
+		},
+		{
+			Method:     "POST",
+			Path:       "/search-licenses",
+			Func:       s.SearchLicenses,
+			Restricted: true,
+			Permission: PermLicenseRead,
+		},
+		{
+			Method:     "POST",
+			Path:       "/list-licenses",
+			Func:       s.ListLicenses,
+			Restricted: true,
+			Permission: PermLicenseRead,
+		},
+		{
+			Method:     "POST",
+			Path:       "/add-build",
+			Func:       s.AddBuild,
+			Restricted: true,
+			Permission: PermAppManage,
+		},
+		{
+			Method:     "POST",
+			Path:       "/update-build",
+			Func:       s.UpdateBuild,
+			Restricted: true,
+			Permission: PermAppManage,
+		},
+		{
+			Method:     "POST",
+			Path:       "/list-builds",
+			Func:       s.ListBuilds,
+			Restricted: true,
+			Permission: PermAppRead,
+		},
+		{
+			Method:     "POST",
+			Path:       "/set-application-status",
+			Func:       s.SetApplicationStatus,
+			Restricted: true,
+			Permission: PermAppManage,
+		},
+		{
+			Method:     "POST",
+			Path:       "/get-policy",
+			Func:       s.GetPolicy,
+			Restricted: true,
+			Permission: PermAppRead,
+		},
+		{
+			Method:     "POST",
+			Path:       "/update-policy",
+			Func:       s.UpdatePolicy,
+			Restricted: true,
+			Permission: PermAppManage,
+		},
+		{
+			Method:     "POST",
+			Path:       "/revoke-license",
+			Func:       s.RevokeLicense,
+			Restricted: true,
+			Permission: PermLicenseRevoke,
+		},
+		{
+			Method:     "POST",
+			Path:       "/create-webhook",
+			Func:       s.CreateWebhook,
+			Restricted: true,
+			Permission: PermWebhookManage,
+		},
+		{
+			Method:     "POST",
+			Path:       "/delete-webhook",
+			Func:       s.DeleteWebhook,
+			Restricted: true,
+			Permission: PermWebhookManage,
+		},
+		{
+			Method:     "POST",
+			Path:       "/list-webhooks",
+			Func:       s.ListWebhooks,
+			Restricted: true,
+			Permission: PermWebhookRead,
+		},
+		{
+			Method:     "POST",
+			Path:       "/list-webhook-deliveries",
+			Func:       s.ListWebhookDeliveries,
+			Restricted: true,
+			Permission: PermWebhookRead,
+		},
+		{
+			Method:     "POST",
+			Path:       "/retry-webhook-delivery",
+			Func:       s.RetryWebhookDelivery,
+			Restricted: true,
+			Permission: PermWebhookManage,
+		},
+		{
+			Method:     "POST",
+			Path:       "/variable",
+			Func:       s.GetVariable,
+			Restricted: false,
+		},
+		{
+			Method:     "POST",
+			Path:       "/set-variable",
+			Func:       s.SetVariable,
+			Restricted: true,
+			Permission: PermVariableManage,
+		},
+		{
+			Method:     "POST",
+			Path:       "/delete-variable",
+			Func:       s.DeleteVariable,
+			Restricted: true,
+			Permission: PermVariableManage,
+		},
+		{
+			Method:     "POST",
+			Path:       "/list-variables",
+			Func:       s.ListVariables,
+			Restricted: true,
+			Permission: PermVariableRead,
+		},
+		{
+			Method:     "POST",
+			Path:       "/delete-license",
+			Func:       s.DeleteLicense,
+			Restricted: true,
+			Permission: PermLicenseDelete,
+		},
+		{
+			Method:     "POST",
+			Path:       "/delete-application",
+			Func:       s.DeleteApplication,
+			Restricted: true,
+			Permission: PermAppDelete,
+		},
+		{
+			Method:     "POST",
+			Path:       "/delete-owner",
+			Func:       s.DeleteOwner,
+			Restricted: true,
+			Permission: PermOwnerDelete,
+		},
+		{
+			Method:     "POST",
+			Path:       "/list-archive",
+			Func:       s.ListArchive,
+			Restricted: true,
+			Permission: PermArchiveRead,
+		},
+		{
+			Method:     "POST",
+			Path:       "/restore-archive",
+			Func:       s.RestoreArchive,
+			Restricted: true,
+			Permission: PermArchiveRestore,
+		},
+		{
+			Method:     "POST",
+			Path:       "/set-user-role",
+			Func:       s.SetUserRole,
+			Restricted: true,
+			Permission: PermUserManage,
+		},
+		{
+			Method:     "POST",
+			Path:       "/invite-member",
+			Func:       s.InviteMember,
+			Restricted: true,
+			Permission: PermMemberManage,
+		},
+		{
+			Method:     "POST",
+			Path:       "/accept-invitation",
+			Func:       s.AcceptInvitation,
+			Restricted: true,
+		},
+		{
+			Method:     "POST",
+			Path:       "/list-members",
+			Func:       s.ListMembers,
+			Restricted: true,
+			Permission: PermMemberRead,
+		},
+		{
+			Method:     "POST",
+			Path:       "/remove-member",
+			Func:       s.RemoveMember,
+			Restricted: true,
+			Permission: PermMemberManage,
+		},
+		{
+			Method:     "POST",
+			Path:       "/revoke-invitation",
+			Func:       s.RevokeInvitation,
+			Restricted: true,
+			Permission: PermMemberManage,
+		},
+		{
+			Method:     "POST",
+			Path:       "/create-api-token",
+			Func:       s.CreateAPIToken,
+			Restricted: true,
+			Permission: PermTokenManage,
+		},
+		{
+			Method:     "POST",
+			Path:       "/list-api-tokens",
+			Func:       s.ListAPITokens,
+			Restricted: true,
+			Permission: PermTokenManage,
+		},
+		{
+			Method:     "POST",
+			Path:       "/revoke-api-token",
+			Func:       s.RevokeAPIToken,
+			Restricted: true,
+			Permission: PermTokenManage,
+		},
+		{
+			Method:     "POST",
+			Path:       "/create-reseller",
+			Func:       s.CreateReseller,
+			Restricted: true,
+			Permission: PermResellerManage,
+		},
+		{
+			Method:     "POST",
+			Path:       "/update-reseller",
+			Func:       s.UpdateReseller,
+			Restricted: true,
+			Permission: PermResellerManage,
+		},
+		{
+			Method:     "POST",
+			Path:       "/list-resellers",
+			Func:       s.ListResellers,
+			Restricted: true,
+			Permission: PermResellerRead,
+		},
+		{
+			Method:     "POST",
+			Path:       "/delete-reseller",
+			Func:       s.DeleteReseller,
+			Restricted: true,
+			Permission: PermResellerManage,
+		},
+		{
+			Method:     "POST",
+			Path:       "/reseller-usage",
+			Func:       s.ResellerUsage,
+			Restricted: true,
+			Permission: PermResellerRead,
+		},
+		{
+			Method:     "POST",
+			Path:       "/reseller-info",
+			Func:       s.ResellerInfo,
+			Restricted: true,
+		},
+		{
+			Method:     "POST",
+			Path:       "/reseller-create-license",
+			Func:       s.ResellerCreateLicense,
+			Restricted: true,
+		},
+		{
+			Method:     "POST",
+			Path:       "/set-owner-plan",
+			Func:       s.SetOwnerPlan,
+			Restricted: true,
+			Permission: PermOwnerPlan,
+		},
+		{
+			Method:     "POST",
+			Path:       "/owner-plan",
+			Func:       s.GetOwnerPlan,
+			Restricted: true,
+			Permission: PermAppRead,
+		},
+		{
+			Method:     "POST",
+			Path:       "/set-access-rule",
+			Func:       s.SetAccessRule,
+			Restricted: true,
+			Permission: PermAppManage,
+		},
+		{
+			Method:     "POST",
+			Path:       "/list-access-rules",
+			Func:       s.ListAccessRules,
+			Restricted: true,
+			Permission: PermAppRead,
+		},
+		{
+			Method:     "POST",
+			Path:       "/delete-access-rule",
+			Func:       s.DeleteAccessRule,
+			Restricted: true,
+			Permission: PermAppManage,
+		},
+		{
+			Method:     "POST",
+			Path:       "/change-password",
+			Func:       s.ChangePassword,
+			Restricted: true,
+		},
+		{
+			Method:     "POST",
+			Path:       "/change-username",
+			Func:       s.ChangeUsername,
+			Restricted: true,
+		},
+		{
+			Method:     "POST",
+			Path:       "/delete-account",
+			Func:       s.DeleteAccount,
+			Restricted: true,
+		},
+		{
+			Method:     "POST",
+			Path:       "/login-2fa",
+			Func:       s.LoginTwoFactor,
+			Restricted: false,
+		},
+		{
+			Method:     "POST",
+			Path:       "/enroll-2fa",
+			Func:       s.EnrollTwoFactor,
+			Restricted: true,
+		},
+		{
+			Method:     "POST",
+			Path:       "/confirm-2fa",
+			Func:       s.ConfirmTwoFactor,
+			Restricted: true,
+		},
+		{
+			Method:     "POST",
+			Path:       "/disable-2fa",
+			Func:       s.DisableTwoFactor,
+			Restricted: true,
+		},
+		{
+			Method:     "POST",
+			Path:       "/recovery-codes",
+			Func:       s.RegenerateRecoveryCodes,
+			Restricted: true,
+		},
+		{
+			Method:     "POST",
+			Path:       "/set-email",
+			Func:       s.SetEmail,
+			Restricted: true,
+		},
+		{
+			Method:     "POST",
+			Path:       "/resend-verification",
+			Func:       s.ResendVerification,
+			Restricted: true,
+		},
+		{
+			Method:     "POST",
+			Path:       "/verify-email",
+			Func:       s.VerifyEmail,
+			Restricted: false,
+		},
+		{
+			Method:     "POST",
+			Path:       "/forgot-password",
+			Func:       s.ForgotPassword,
+			Restricted: false,
+		},
+		{
+			Method:     "POST",
+			Path:       "/reset-password",
+			Func:       s.ResetPassword,
+			Restricted: false,
+		},
+		{
+			Method:     "POST",
+			Path:       "/set-user-status",
+			Func:       s.SetUserStatus,
+			Restricted: true,
+			Permission: PermUserManage,
+		},
+		{
+			Method:     "POST",
+			Path:       "/list-users",
+			Func:       s.ListUsers,
+			Restricted: true,
+			Permission: PermUserManage,
+		},
+		{
+			Method:     "POST",
+			Path:       "/force-logout",
+			Func:       s.ForceLogout,
+			Restricted: true,
+			Permission: PermUserManage,
+		},
+		{
+			Method:     "POST",
+			Path:       "/list-audit-logs",
+			Func:       s.ListAuditLogs,
+			Restricted: true,
+			Permission: PermAuditRead,
+		},
+		{
+			Method:     "POST",
+			Path:       "/list-sessions",
+			Func:       s.ListSessions,
+			Restricted: true,
+		},
+		{
+			Method:     "POST",
+			Path:       "/revoke-session",
+			Func:       s.RevokeSession,
+			Restricted: true,
+		},
+		{
+			Method:     "POST",
+			Path:       "/revoke-sessions",
+			Func:       s.RevokeSessions,
+			Restricted: true,
 		},
 	}
 
 	for _, v := range Routes {
 		log.Info("Binding -> %v [%v]", v.Path, v.Method)
 		if v.Restricted {
-			s.client.Add([]string{v.Method}, v.Path, v.Func, jwtMiddleware)
+			s.client.Add([]string{v.Method}, v.Path, v.Func, authenticate, s.requirePermission(v.Permission))
 		} else {
 			s.client.Add([]string{v.Method}, v.Path, v.Func)
 		}
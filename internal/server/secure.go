@@ -3,9 +3,11 @@ package server
 import (
 	"encoding/base64"
 	"encoding/json"
+	"net"
 	"time"
 
 	crypto "github.com/Aran404/Goauth/internal/crypto"
+	mongo "github.com/Aran404/Goauth/internal/database/mongo"
 	log "github.com/Aran404/Goauth/internal/logger"
 	types "github.com/Aran404/Goauth/internal/types"
 	utils "github.com/Aran404/Goauth/internal/utils"
@@ -13,6 +15,8 @@ import (
 	"github.com/gofiber/fiber/v3"
 	"github.com/google/uuid"
 	"github.com/monnand/dhkx"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func (s *Server) ServeHello(conn *fastws.Conn) {
@@ -42,17 +46,21 @@ func (s *Server) ClientHello(conn *fastws.Conn) error {
 		return err
 	}
 
-	otherPub, nonce, err := c.RecvHello()
+	hello, err := c.RecvHello()
 	if err != nil {
 		return err
 	}
 
-	decodedKey, err := base64.StdEncoding.DecodeString(*otherPub)
+	if err := s.checkHelloAccess(c, hello); err != nil {
+		return err
+	}
+
+	decodedKey, err := base64.StdEncoding.DecodeString(hello.Public)
 	if err != nil {
 		return err
 	}
 
-	decodedNonce, err := base64.StdEncoding.DecodeString(*nonce)
+	decodedNonce, err := base64.StdEncoding.DecodeString(hello.Nonce)
 	if err != nil {
 		return err
 	}
@@ -107,6 +115,53 @@ func (s *Server) ClientHello(conn *fastws.Conn) error {
 	return nil
 }
 
+// checkHelloAccess refuses clients blocked by the access rules of the application they named.
+// Clients that don't name one are checked once they validate a license instead.
+func (s *Server) checkHelloAccess(c *Connection, hello *Hello) error {
+	if hello.AppID == "" {
+		return nil
+	}
+
+	appID, err := primitive.ObjectIDFromHex(hello.AppID)
+	if err != nil {
+		return c.SendError(types.ErrorInvalidApp)
+	}
+
+	// Unknown applications are refused on the _id index before their rules are looked up
+	exists, err := s.db.Exists(s.dbCtx, mongo.Applications, bson.M{"_id": appID})
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return c.SendError(types.ErrorInvalidApp)
+	}
+
+	ip := ""
+	if addr, ok := c.RemoteAddr().(*net.TCPAddr); ok {
+		ip = addr.IP.String()
+	}
+
+	if err := s.checkAccess(appID, ip, hello.Fingerprint, "key exchange"); err != nil {
+		return c.SendError(err)
+	}
+
+	return nil
+}
+
+// SendError tells the client why the exchange was refused and returns err so the connection gets closed.
+func (c *Connection) SendError(err error) error {
+	payload, merr := json.Marshal(fiber.Map{"error": types.ProperError(err)})
+	if merr != nil {
+		return merr
+	}
+
+	if _, werr := c.WriteMessage(fastws.ModeBinary, payload); werr != nil {
+		return werr
+	}
+	return err
+}
+
 func (c *Connection) SendHello(payload []byte) error {
 	staged, err := json.Marshal(fiber.Map{"public": payload})
 	if err != nil {
@@ -117,7 +172,7 @@ func (c *Connection) SendHello(payload []byte) error {
 	return err
 }
 
-func (c *Connection) RecvHello() (*string, *string, error) {
+func (c *Connection) RecvHello() (*Hello, error) {
 	var (
 		payload []byte
 		err     error
@@ -125,53 +180,55 @@ func (c *Connection) RecvHello() (*string, *string, error) {
 
 	_, payload, err = c.ReadMessage(payload[:0])
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
 	if payload == nil {
-		return nil, nil, types.ErrorInvalidHello
+		return nil, types.ErrorInvalidHello
 	}
 
-	data, nonce, err := c.validateHelloPayload(payload)
+	hello, err := c.validateHelloPayload(payload)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	if data == "" || nonce == "" {
-		payload, err := json.Marshal(fiber.Map{"error": err.Error()})
+	if hello.Public == "" || hello.Nonce == "" {
+		payload, err := json.Marshal(fiber.Map{"error": types.ErrorInvalidHello.Error()})
 		if err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 
 		if _, err = c.WriteMessage(fastws.ModeBinary, payload); err != nil {
-			return nil, nil, err
+			return nil, err
 		}
 
 		return c.RecvHello()
 	}
 
-	return &data, &nonce, nil
+	return hello, nil
 }
 
-func (c *Connection) validateHelloPayload(payload []byte) (publicKey string, nonce string, err error) {
+func (c *Connection) validateHelloPayload(payload []byte) (*Hello, error) {
 	var data fiber.Map
-	if err = json.Unmarshal(payload, &data); err != nil {
-		err = types.ErrorInvalidJSON
-		return
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return nil, types.ErrorInvalidJSON
 	}
 
 	publicKey, ok := data["public"].(string)
 	if !ok || len(publicKey) == 0 {
-		err = types.ErrorInvalidHello
-		return
+		return nil, types.ErrorInvalidHello
 	}
 
-	nonce, ok = data["nonce"].(string)
+	nonce, ok := data["nonce"].(string)
 	if !ok || len(nonce) == 0 {
-		err = types.ErrorInvalidHello
+		return nil, types.ErrorInvalidHello
 	}
 
-	return
+	// Both are optional, anything that isn't a string is ignored
+	appID, _ := data["app_id"].(string)
+	fingerprint, _ := data["fingerprint"].(string)
+
+	return &Hello{Public: publicKey, Nonce: nonce, AppID: appID, Fingerprint: fingerprint}, nil
 }
 
 func (c *Connection) SendFinalAck(sessionID string, hmacSeed int64) error {
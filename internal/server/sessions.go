@@ -0,0 +1,242 @@
+package server
+
+import (
+	"time"
+
+	crypto "github.com/Aran404/Goauth/internal/crypto"
+	mongo "github.com/Aran404/Goauth/internal/database/mongo"
+	types "github.com/Aran404/Goauth/internal/types"
+	"github.com/gofiber/fiber/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const maxDeviceName = 64
+
+// sessionDevice names the device a session was started from, the X-Device-Name header wins over the user agent.
+func sessionDevice(c fiber.Ctx) string {
+	device := c.Get("X-Device-Name")
+	if device == "" {
+		device = c.Get("User-Agent")
+	}
+
+	if len(device) > maxDeviceName {
+		device = device[:maxDeviceName]
+	}
+
+	return device
+}
+
+// startSession issues a key pair bound to a new refresh session, the user's other sessions are kept.
+func (s *Server) startSession(c fiber.Ctx, user *mongo.UserObject) (*fiber.Map, error) {
+	sessionID := primitive.NewObjectID()
+	resp, expiresAt, err := s.GenerateKeyPair(user, sessionID.Hex())
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	record := &mongo.RefreshSessionObject{
+		ID:        sessionID,
+		UserID:    user.ID,
+		Token:     crypto.KeyedHash((*resp)["refresh_token"].(string), s.licensePepper),
+		Device:    sessionDevice(c),
+		IP:        c.IP(),
+		CreatedAt: now,
+		LastUsed:  now,
+		ExpiresAt: expiresAt,
+	}
+
+	if err := s.db.Create(s.dbCtx, mongo.RefreshSessions, record); err != nil {
+		return nil, err
+	}
+
+	if err := s.pruneSessions(user.ID); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// pruneSessions drops expired sessions and, past security.max_sessions, the ones used least recently.
+func (s *Server) pruneSessions(userID primitive.ObjectID) error {
+	expired := bson.M{"user_id": userID, "expires_at": bson.M{"$lte": time.Now().Unix()}}
+	if err := s.db.Delete(s.dbCtx, mongo.RefreshSessions, expired); err != nil && err != types.ErrorNoMatches {
+		return err
+	}
+
+	limit := types.Cfg.Security.MaxSessions
+	if limit <= 0 {
+		return nil
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "last_used", Value: -1}, {Key: "_id", Value: -1}}).SetSkip(limit)
+	unparsed, err := s.db.Find(s.dbCtx, mongo.RefreshSessions, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return err
+	}
+
+	stale := bson.A{}
+	for _, v := range unparsed {
+		stale = append(stale, v["_id"])
+	}
+
+	if len(stale) == 0 {
+		return nil
+	}
+
+	return s.db.Delete(s.dbCtx, mongo.RefreshSessions, bson.M{"_id": bson.M{"$in": stale}})
+}
+
+// revokeSessions signs a user out of every session but the one given, which may be empty.
+func (s *Server) revokeSessions(userID primitive.ObjectID, except string) error {
+	query := bson.M{"user_id": userID}
+	if id, err := primitive.ObjectIDFromHex(except); err == nil {
+		query["_id"] = bson.M{"$ne": id}
+	}
+
+	if err := s.db.Delete(s.dbCtx, mongo.RefreshSessions, query); err != nil && err != types.ErrorNoMatches {
+		return err
+	}
+
+	return nil
+}
+
+// replaceSessions signs the user out everywhere and starts a fresh session for the caller.
+func (s *Server) replaceSessions(c fiber.Ctx, user *mongo.UserObject) (*fiber.Map, error) {
+	if err := s.revokeSessions(user.ID, ""); err != nil {
+		return nil, err
+	}
+
+	return s.startSession(c, user)
+}
+
+// checkSession makes sure the session an access token belongs to hasn't been revoked.
+func (s *Server) checkSession(user *mongo.UserObject, sessionID string) error {
+	id, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return fiber.ErrUnauthorized
+	}
+
+	exists, err := s.db.Exists(s.dbCtx, mongo.RefreshSessions, bson.M{"_id": id, "user_id": user.ID})
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return fiber.ErrUnauthorized
+	}
+
+	return nil
+}
+
+func sessionView(v *mongo.RefreshSessionObject, current string) fiber.Map {
+	return fiber.Map{
+		"id":         v.ID.Hex(),
+		"device":     v.Device,
+		"ip":         v.IP,
+		"created_at": v.CreatedAt,
+		"last_used":  v.LastUsed,
+		"expires_at": v.ExpiresAt,
+		"current":    v.ID.Hex() == current,
+	}
+}
+
+// ListSessions returns the devices the user is signed in on, most recently used first
+func (s *Server) ListSessions(c fiber.Ctx) error {
+	session, _, err := s.ParseBody(c)
+	if err != nil {
+		return err
+	}
+
+	fields, err := s.parseJWTFields(c)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.getUser(fields.UserID)
+	if err != nil {
+		return err
+	}
+
+	query := bson.M{"user_id": user.ID, "expires_at": bson.M{"$gt": time.Now().Unix()}}
+	opts := options.Find().SetSort(bson.D{{Key: "last_used", Value: -1}})
+	unparsed, err := s.db.Find(s.dbCtx, mongo.RefreshSessions, query, opts)
+	if err != nil {
+		return err
+	}
+
+	var sessions []mongo.RefreshSessionObject
+	if err := mongo.ReadAllInto[mongo.RefreshSessionObject](unparsed, &sessions); err != nil {
+		return err
+	}
+
+	views := []fiber.Map{}
+	for i := range sessions {
+		views = append(views, sessionView(&sessions[i], fields.SessionID))
+	}
+
+	returnDump := fiber.Map{"success": true, "sessions": views, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// RevokeSession signs one device out, its access token stops working right away
+func (s *Server) RevokeSession(c fiber.Ctx) error {
+	var msg SessionIDMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := primitive.ObjectIDFromHex(msg.SessionID)
+	if err != nil {
+		return types.ErrorSessionNotFound
+	}
+
+	if err := s.db.Delete(s.dbCtx, mongo.RefreshSessions, bson.M{"_id": id, "user_id": user.ID}); err != nil {
+		if err == types.ErrorNoMatches {
+			return types.ErrorSessionNotFound
+		}
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// RevokeSessions signs out every device, keep_current leaves the one making the request signed in
+func (s *Server) RevokeSessions(c fiber.Ctx) error {
+	var msg RevokeSessionsMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	fields, err := s.parseJWTFields(c)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.getUser(fields.UserID)
+	if err != nil {
+		return err
+	}
+
+	except := ""
+	if msg.KeepCurrent {
+		except = fields.SessionID
+	}
+
+	if err := s.revokeSessions(user.ID, except); err != nil {
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
@@ -8,6 +8,7 @@ import (
 	mongo "github.com/Aran404/Goauth/internal/database/mongo"
 	redis "github.com/Aran404/Goauth/internal/database/redis"
 	log "github.com/Aran404/Goauth/internal/logger"
+	mailer "github.com/Aran404/Goauth/internal/mailer"
 	types "github.com/Aran404/Goauth/internal/types"
 	"github.com/dgrr/fastws"
 	"github.com/gofiber/fiber/v3"
@@ -21,14 +22,29 @@ type Server struct {
 	smutex  *sync.Mutex
 	dbmutex *sync.Mutex
 
-	dbCtx     context.Context
-	jwtSecret []byte
+	dbCtx         context.Context
+	jwtSecret     []byte
+	licensePepper []byte
+
+	passwords *crypto.Passwords
+	dummyHash string
+	mailer    mailer.Mailer
 }
 
 type LicenseHolders struct {
 	msg     *LicenseMsg
 	license *mongo.LicenseObject
 	app     *mongo.ApplicationObject
+	owner   *mongo.OwnerObject
+}
+
+// Hello is the client's half of the key exchange.
+// The application and fingerprint are optional, they let blocked clients be refused before a session exists.
+type Hello struct {
+	Public      string
+	Nonce       string
+	AppID       string
+	Fingerprint string
 }
 
 type Session struct {
@@ -52,6 +68,8 @@ type Route struct {
 	Path       string
 	Func       fiber.Handler
 	Restricted bool
+	// Permission is required on top of a valid token, empty allows any signed in user
+	Permission string
 }
 
 func FromMap(m fiber.Map) *Session {
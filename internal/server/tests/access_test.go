@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	mongo "github.com/Aran404/Goauth/internal/database/mongo"
+	server "github.com/Aran404/Goauth/internal/server"
+	types "github.com/Aran404/Goauth/internal/types"
+)
+
+func TestNormalizeRule(t *testing.T) {
+	cases := []struct {
+		kind  string
+		value string
+		want  string
+		fails bool
+	}{
+		{kind: mongo.RuleIP, value: "203.0.113.7", want: "203.0.113.7/32"},
+		{kind: mongo.RuleIP, value: "2001:db8::1", want: "2001:db8::1/128"},
+		{kind: mongo.RuleIP, value: "203.0.113.7/24", want: "203.0.113.0/24"},
+		{kind: mongo.RuleIP, value: "203.0.113.300", fails: true},
+		{kind: mongo.RuleIP, value: "203.0.113.0/33", fails: true},
+		{kind: mongo.RuleFingerprint, value: "device-1", want: "device-1"},
+		{kind: "country", value: "NL", fails: true},
+	}
+
+	for _, tc := range cases {
+		got, err := server.NormalizeRule(tc.kind, tc.value)
+		if tc.fails {
+			if !errors.Is(err, types.ErrorInvalidRule) {
+				t.Errorf("%v %q: expected an invalid rule, got %q and %v", tc.kind, tc.value, got, err)
+			}
+			continue
+		}
+
+		if err != nil || got != tc.want {
+			t.Errorf("%v %q: expected %q, got %q and %v", tc.kind, tc.value, tc.want, got, err)
+		}
+	}
+}
+
+func TestRuleMatches(t *testing.T) {
+	cases := []struct {
+		rule        mongo.AccessRuleObject
+		ip          string
+		fingerprint string
+		want        bool
+	}{
+		{rule: mongo.AccessRuleObject{Kind: mongo.RuleIP, Value: "203.0.113.0/24"}, ip: "203.0.113.7", want: true},
+		{rule: mongo.AccessRuleObject{Kind: mongo.RuleIP, Value: "203.0.113.0/24"}, ip: "198.51.100.7", want: false},
+		{rule: mongo.AccessRuleObject{Kind: mongo.RuleIP, Value: "203.0.113.0/24"}, ip: "", want: false},
+		{rule: mongo.AccessRuleObject{Kind: mongo.RuleIP, Value: "2001:db8::/32"}, ip: "2001:db8::1", want: true},
+		{rule: mongo.AccessRuleObject{Kind: mongo.RuleIP, Value: "not a range"}, ip: "203.0.113.7", want: false},
+		{rule: mongo.AccessRuleObject{Kind: mongo.RuleFingerprint, Value: "device-1"}, fingerprint: "device-1", want: true},
+		{rule: mongo.AccessRuleObject{Kind: mongo.RuleFingerprint, Value: "device-1"}, fingerprint: "device-2", want: false},
+		{rule: mongo.AccessRuleObject{Kind: mongo.RuleFingerprint, Value: ""}, fingerprint: "", want: false},
+	}
+
+	for _, tc := range cases {
+		if got := server.RuleMatches(&tc.rule, net.ParseIP(tc.ip), tc.fingerprint); got != tc.want {
+			t.Errorf("%v %q against %q/%q: expected %v, got %v", tc.rule.Kind, tc.rule.Value, tc.ip, tc.fingerprint, tc.want, got)
+		}
+	}
+}
+
+func TestEvaluateRules(t *testing.T) {
+	denyIP := mongo.AccessRuleObject{Kind: mongo.RuleIP, Action: mongo.RuleDeny, Value: "203.0.113.0/24"}
+	allowIP := mongo.AccessRuleObject{Kind: mongo.RuleIP, Action: mongo.RuleAllow, Value: "203.0.113.7/32"}
+	allowDevice := mongo.AccessRuleObject{Kind: mongo.RuleFingerprint, Action: mongo.RuleAllow, Value: "device-1"}
+
+	cases := []struct {
+		name        string
+		rules       []mongo.AccessRuleObject
+		ip          string
+		fingerprint string
+		denied      bool
+		byRule      bool
+	}{
+		{name: "No Rules", ip: "203.0.113.7", fingerprint: "device-1"},
+		{name: "Denied", rules: []mongo.AccessRuleObject{denyIP}, ip: "203.0.113.9", denied: true, byRule: true},
+		{name: "Deny Wins Over Allow", rules: []mongo.AccessRuleObject{allowIP, denyIP}, ip: "203.0.113.7", denied: true, byRule: true},
+		{name: "On Allow List", rules: []mongo.AccessRuleObject{allowIP}, ip: "203.0.113.7"},
+		{name: "Off Allow List", rules: []mongo.AccessRuleObject{allowIP}, ip: "198.51.100.7", denied: true},
+		{name: "Other Kind Restricted", rules: []mongo.AccessRuleObject{allowIP, allowDevice}, ip: "203.0.113.7", fingerprint: "device-2", denied: true},
+		{name: "Empty Fingerprint Not Checked", rules: []mongo.AccessRuleObject{allowDevice}, ip: "198.51.100.7"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rule, err := server.EvaluateRules(tc.rules, tc.ip, tc.fingerprint)
+			if tc.denied != errors.Is(err, types.ErrorAccessDenied) {
+				t.Fatalf("expected denied to be %v, got %v", tc.denied, err)
+			}
+
+			if tc.byRule != (rule != nil) {
+				t.Errorf("expected a deny rule to be returned: %v, got %v", tc.byRule, rule)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	mailer "github.com/Aran404/Goauth/internal/mailer"
+)
+
+func TestFileMailer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mail.log")
+	m := mailer.NewFile(path, "Goauth <noreply@localhost>")
+
+	if err := m.Send("alice@example.com", "Reset your password\r\nBcc: eve@example.com", "Token:\nabc123"); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := string(raw)
+
+	for _, expect := range []string{"To: alice@example.com\r\n", "Subject: Reset your passwordBcc: eve@example.com\r\n", "Token:\r\nabc123"} {
+		if !strings.Contains(content, expect) {
+			t.Errorf("expected %q in %q", expect, content)
+		}
+	}
+
+	if strings.Contains(content, "\r\nBcc:") {
+		t.Error("subject was able to add a header")
+	}
+}
@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	crypto "github.com/Aran404/Goauth/internal/crypto"
+)
+
+func TestArgon2idHash(t *testing.T) {
+	hasher := &crypto.Argon2idHasher{Memory: 1024, Iterations: 1, Parallelism: 1}
+	passwords := crypto.NewPasswords(hasher, 1, 0)
+
+	hash, err := passwords.Hash("correct horse battery staple")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Fatalf("unexpected hash format %v", hash)
+	}
+
+	if ok, rehash, _ := passwords.Verify("correct horse battery staple", hash); !ok || rehash {
+		t.Fatalf("expected a match without rehash, got %v %v", ok, rehash)
+	}
+
+	if ok, _, _ := passwords.Verify("wrong", hash); ok {
+		t.Fatal("wrong password matched")
+	}
+
+	// Changing the parameters marks the hash as outdated but it still verifies
+	stronger := crypto.NewPasswords(&crypto.Argon2idHasher{Memory: 2048, Iterations: 1, Parallelism: 1}, 1, 0)
+	if ok, rehash, _ := stronger.Verify("correct horse battery staple", hash); !ok || !rehash {
+		t.Fatalf("expected a match with rehash, got %v %v", ok, rehash)
+	}
+}
+
+func TestBcryptRehash(t *testing.T) {
+	legacy, err := crypto.NewBcryptHasher(4).Hash("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	passwords := crypto.NewPasswords(&crypto.Argon2idHasher{Memory: 1024, Iterations: 1, Parallelism: 1}, 1, 0)
+	if ok, rehash, _ := passwords.Verify("hunter2", legacy); !ok || !rehash {
+		t.Fatalf("expected bcrypt hash to match and need a rehash, got %v %v", ok, rehash)
+	}
+
+	if ok, _, _ := passwords.Verify("hunter3", legacy); ok {
+		t.Fatal("wrong password matched")
+	}
+
+	if ok, _, _ := passwords.Verify("hunter2", "plain text"); ok {
+		t.Fatal("unknown hash format matched")
+	}
+}
+
+// slowHasher holds its worker until release is closed.
+type slowHasher struct {
+	crypto.Argon2idHasher
+	release chan struct{}
+}
+
+func (h *slowHasher) Hash(password string) (string, error) {
+	<-h.release
+	return "", nil
+}
+
+func TestPasswordsBusy(t *testing.T) {
+	hasher := &slowHasher{release: make(chan struct{})}
+	passwords := crypto.NewPasswords(hasher, 1, 50*time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		passwords.Hash("first")
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	if _, err := passwords.Hash("second"); !errors.Is(err, crypto.ErrBusy) {
+		t.Fatalf("expected ErrBusy while the only worker is taken, got %v", err)
+	}
+
+	close(hasher.release)
+	<-done
+
+	if _, err := passwords.Hash("third"); err != nil {
+		t.Fatalf("expected the worker to be free again, got %v", err)
+	}
+}
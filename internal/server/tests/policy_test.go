@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	types "github.com/Aran404/Goauth/internal/types"
+	utils "github.com/Aran404/Goauth/internal/utils"
+)
+
+func TestCheckPassword(t *testing.T) {
+	policy := &types.PasswordPolicy{
+		MinLength:          8,
+		MaxLength:          64,
+		RequireUpper:       true,
+		RequireLower:       true,
+		RequireDigit:       true,
+		RequireSpecial:     true,
+		DisallowUsername:   true,
+		DisallowedPatterns: []string{"(?i)password"},
+	}
+
+	cases := []struct {
+		password string
+		message  string
+	}{
+		{password: "Str0ng!Enough", message: ""},
+		{password: "Sh0rt!", message: "at least 8"},
+		{password: "weak0!weak", message: "uppercase"},
+		{password: "WEAK0!WEAK", message: "lowercase"},
+		{password: "NoDigits!!", message: "number"},
+		{password: "NoSpecial00", message: "special"},
+		{password: "Alice!2024x", message: "username"},
+		{password: "MyPassword1!", message: "disallowed"},
+	}
+
+	for _, c := range cases {
+		err := utils.CheckPassword(c.password, "alice", policy)
+		if c.message == "" {
+			if err != nil {
+				t.Errorf("%v: unexpected error %v", c.password, err)
+			}
+			continue
+		}
+
+		var detailed *types.DetailedError
+		if !errors.As(err, &detailed) || !errors.Is(err, types.ErrorInsecurePassword) {
+			t.Errorf("%v: expected an insecure password error, got %v", c.password, err)
+			continue
+		}
+
+		if !strings.Contains(detailed.Message, c.message) {
+			t.Errorf("%v: expected %q in %q", c.password, c.message, detailed.Message)
+		}
+	}
+}
+
+func TestBreachedPassword(t *testing.T) {
+	dir := t.TempDir()
+	sum := sha1.Sum([]byte("Tr0ub4dor&3"))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	content := "0018A45C4D1DEF81644B54AB7F969B88D65:3\n" + hash[5:] + ":42\n"
+	if err := os.WriteFile(filepath.Join(dir, hash[:5]), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	breached, err := utils.Breached("Tr0ub4dor&3", dir)
+	if err != nil || !breached {
+		t.Fatalf("expected password to be breached, got %v %v", breached, err)
+	}
+
+	breached, err = utils.Breached("Correct-Horse-9", dir)
+	if err != nil || breached {
+		t.Fatalf("expected password not to be breached, got %v %v", breached, err)
+	}
+
+	err = utils.CheckPassword("Tr0ub4dor&3", "", &types.PasswordPolicy{BreachedList: dir})
+	if !errors.Is(err, types.ErrorInsecurePassword) {
+		t.Fatalf("expected breached password to be refused, got %v", err)
+	}
+}
+
+func TestCheckUsername(t *testing.T) {
+	policy := &types.UsernamePolicy{MinLength: 3, MaxLength: 20, Pattern: "^[A-Za-z0-9_.-]+$", DisallowedPatterns: []string{"(?i)^admin$"}}
+
+	cases := []struct {
+		username string
+		expect   error
+	}{
+		{username: "alice_01", expect: nil},
+		{username: "al", expect: types.ErrorIncorrectLength},
+		{username: strings.Repeat("a", 21), expect: types.ErrorIncorrectLength},
+		{username: "alice bob", expect: types.ErrorInvalidUsername},
+		{username: "Admin", expect: types.ErrorInvalidUsername},
+	}
+
+	for _, c := range cases {
+		if err := utils.CheckUsername(c.username, policy); !errors.Is(err, c.expect) {
+			t.Errorf("%v: expected %v, got %v", c.username, c.expect, err)
+		}
+	}
+}
+
+func TestDefaultPolicies(t *testing.T) {
+	password := types.DefaultPasswordPolicy()
+	for _, weak := range []string{"Sh0rt!", "alllower1!", "NoDigits!!", "NoSpecial00"} {
+		if err := utils.CheckPassword(weak, "", &password); !errors.Is(err, types.ErrorInsecurePassword) {
+			t.Errorf("%v: expected the default policy to refuse it, got %v", weak, err)
+		}
+	}
+
+	if err := utils.CheckPassword("Str0ng!Enough", "", &password); err != nil {
+		t.Errorf("expected the default policy to accept a strong password, got %v", err)
+	}
+
+	username := types.DefaultUsernamePolicy()
+	if err := utils.CheckUsername("", &username); !errors.Is(err, types.ErrorIncorrectLength) {
+		t.Errorf("expected an empty username to be refused, got %v", err)
+	}
+
+	if err := utils.CheckUsername(strings.Repeat("a", 21), &username); !errors.Is(err, types.ErrorIncorrectLength) {
+		t.Errorf("expected a 21 character username to be refused, got %v", err)
+	}
+}
+
+func TestUsernameWithoutMaxLength(t *testing.T) {
+	policy := &types.UsernamePolicy{MinLength: 0, MaxLength: 0}
+
+	var detailed *types.DetailedError
+	if err := utils.CheckUsername("", policy); !errors.As(err, &detailed) || !strings.Contains(detailed.Message, "at least 1 ") {
+		t.Errorf("expected an at least 1 message, got %v", err)
+	}
+
+	if err := utils.CheckUsername(strings.Repeat("a", 200), policy); err != nil {
+		t.Errorf("expected no upper limit, got %v", err)
+	}
+}
+
+func TestCompilePolicies(t *testing.T) {
+	if err := utils.CompilePolicies(&types.PasswordPolicy{DisallowedPatterns: []string{"(?i)password"}}, &types.UsernamePolicy{Pattern: "^[a-z]+$"}); err != nil {
+		t.Fatalf("expected valid patterns to compile, got %v", err)
+	}
+
+	if err := utils.CompilePolicies(&types.PasswordPolicy{}, &types.UsernamePolicy{DisallowedPatterns: []string{"(unclosed"}}); err == nil {
+		t.Fatal("expected an invalid pattern to be refused")
+	}
+}
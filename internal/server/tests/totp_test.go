@@ -0,0 +1,57 @@
+package tests
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+
+	crypto "github.com/Aran404/Goauth/internal/crypto"
+)
+
+// Test vectors from RFC 6238 appendix B (SHA1), truncated to 6 digits
+func TestTOTPCode(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	cases := []struct {
+		at     int64
+		expect string
+	}{
+		{at: 59, expect: "287082"},
+		{at: 1111111109, expect: "081804"},
+		{at: 1111111111, expect: "050471"},
+		{at: 1234567890, expect: "005924"},
+		{at: 2000000000, expect: "279037"},
+	}
+
+	for _, c := range cases {
+		code, err := crypto.TOTPCode(secret, crypto.TOTPCounter(time.Unix(c.at, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if code != c.expect {
+			t.Errorf("TOTPCode at %v = %v, expected %v", c.at, code, c.expect)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := crypto.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	previous, err := crypto.TOTPCode(secret, crypto.TOTPCounter(now)-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if counter, ok := crypto.ValidateTOTP(secret, previous, now, 1); !ok || counter != crypto.TOTPCounter(now)-1 {
+		t.Errorf("ValidateTOTP rejected the previous step")
+	}
+
+	if _, ok := crypto.ValidateTOTP(secret, previous, now, 0); ok {
+		t.Errorf("ValidateTOTP accepted the previous step without skew")
+	}
+}
@@ -127,3 +127,40 @@ func TestCreateLicense(t *testing.T) {
 		})
 	}
 }
+
+// TestLicenseChecksum makes sure generated keys validate and typos do not.
+func TestLicenseChecksum(t *testing.T) {
+	const salt = "6675d1f0c3a1b2c3d4e5f607"
+
+	for _, v := range []utils.LicenseSettings{
+		{Salt: salt},
+		{Mask: "test-****-****", Salt: salt},
+		{Mask: "****-****", OnlyLowercase: true, Salt: salt},
+	} {
+		license := utils.CreateLicense(v)
+		if !utils.ValidLicenseChecksum(license, salt) {
+			t.Errorf("Generated license failed its own checksum: %s", license)
+		}
+
+		if utils.ValidLicenseChecksum(license, "other"+salt) {
+			t.Errorf("License validated against another application: %s", license)
+		}
+
+		typo := []byte(license)
+		if typo[0] == '0' {
+			typo[0] = '1'
+		} else {
+			typo[0] = '0'
+		}
+
+		if utils.ValidLicenseChecksum(string(typo), salt) {
+			t.Errorf("Mistyped license passed the checksum: %s", typo)
+		}
+	}
+
+	for _, v := range []string{"", "ABCD", "-ABCD", "ABCD-EF", "ABCD-EFGHI"} {
+		if utils.ValidLicenseChecksum(v, salt) {
+			t.Errorf("Malformed license passed the checksum: %q", v)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	mongo "github.com/Aran404/Goauth/internal/database/mongo"
+	server "github.com/Aran404/Goauth/internal/server"
+	types "github.com/Aran404/Goauth/internal/types"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// receiver verifies signatures like a real webhook consumer would and fails the first failures requests.
+func receiver(t *testing.T, secret string, failures int32) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		timestamp, err := strconv.ParseInt(r.Header.Get("X-Goauth-Timestamp"), 10, 64)
+		if err != nil {
+			t.Errorf("missing timestamp header")
+		}
+
+		if r.Header.Get("X-Goauth-Signature") != server.SignWebhook(secret, timestamp, string(body)) {
+			t.Errorf("signature does not match the body")
+		}
+
+		if calls.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &calls
+}
+
+// attempt runs deliveries the way the worker does until the delivery is no longer pending.
+func attempt(t *testing.T, hook *mongo.WebhookObject, d *mongo.DeliveryObject) map[string]any {
+	for {
+		d.Attempts++
+		code, err := server.SendWebhook(http.DefaultClient, hook, d)
+		update := server.DeliveryUpdate(d, code, err)
+
+		if _, ok := update["status"]; ok {
+			return update
+		}
+
+		if _, ok := update["next_attempt"]; !ok {
+			t.Fatalf("failed attempt %v was not rescheduled", d.Attempts)
+		}
+
+		if d.Attempts > types.Cfg.Webhooks.MaxAttempts {
+			t.Fatalf("delivery kept retrying after %v attempts", d.Attempts)
+		}
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	types.Cfg.Webhooks.MaxAttempts = 3
+	types.Cfg.Webhooks.Backoff = 30
+
+	const secret = "whsec_test"
+	payload := `{"event":"license.activated","data":{}}`
+
+	t.Run("Retried Until Delivered", func(t *testing.T) {
+		srv, calls := receiver(t, secret, 2)
+		hook := &mongo.WebhookObject{URL: srv.URL, Secret: secret}
+		d := &mongo.DeliveryObject{ID: primitive.NewObjectID(), Event: "license.activated", Payload: payload}
+
+		update := attempt(t, hook, d)
+		if update["status"] != mongo.DeliveryDelivered {
+			t.Fatalf("expected delivered, got %v", update["status"])
+		}
+
+		if calls.Load() != 3 || update["response_code"] != http.StatusNoContent {
+			t.Errorf("expected 3 calls ending in 204, got %v calls and %v", calls.Load(), update["response_code"])
+		}
+	})
+
+	t.Run("Dead After Max Attempts", func(t *testing.T) {
+		srv, calls := receiver(t, secret, 100)
+		hook := &mongo.WebhookObject{URL: srv.URL, Secret: secret}
+		d := &mongo.DeliveryObject{ID: primitive.NewObjectID(), Event: "license.activated", Payload: payload}
+
+		update := attempt(t, hook, d)
+		if update["status"] != mongo.DeliveryDead {
+			t.Fatalf("expected dead, got %v", update["status"])
+		}
+
+		if calls.Load() != 3 || update["last_error"] == "" {
+			t.Errorf("expected 3 calls and an error, got %v calls and %q", calls.Load(), update["last_error"])
+		}
+	})
+
+	t.Run("Wrong Secret", func(t *testing.T) {
+		if server.SignWebhook(secret, 1, payload) == server.SignWebhook("other", 1, payload) {
+			t.Error("signature does not depend on the secret")
+		}
+
+		if server.SignWebhook(secret, 1, payload) == server.SignWebhook(secret, 2, payload) {
+			t.Error("signature does not depend on the timestamp")
+		}
+	})
+}
@@ -0,0 +1,230 @@
+package server
+
+import (
+	"time"
+
+	crypto "github.com/Aran404/Goauth/internal/crypto"
+	mongo "github.com/Aran404/Goauth/internal/database/mongo"
+	types "github.com/Aran404/Goauth/internal/types"
+	utils "github.com/Aran404/Goauth/internal/utils"
+	"github.com/gofiber/fiber/v3"
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const apiTokenPrefix = "goa_"
+
+// Permissions that act outside of a single owner can't be given to an API token
+var tokenScopeBlacklist = []string{PermOwnerCreate, PermOwnerDelete, PermOwnerPlan, PermUserManage, PermTokenManage, PermAuditRead}
+
+// apiTokenMiddleware authenticates the X-Api-Token header in place of the JWT middleware.
+// The token is turned into the same claims an access token carries, so handlers don't need to tell them apart.
+// Scopes are intersected with what the creator may do right now, removing them from the team disables their tokens.
+func (s *Server) apiTokenMiddleware(c fiber.Ctx) error {
+	hashed := crypto.KeyedHash(c.Get("X-Api-Token"), s.licensePepper)
+	unparsed, err := s.db.Filter(s.dbCtx, mongo.APITokens, bson.M{"token": hashed}, false, types.ErrorInvalidToken)
+	if err != nil {
+		return err
+	}
+
+	var token mongo.APITokenObject
+	if err := mongo.ReadInto[mongo.APITokenObject](unparsed, &token); err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	if token.ExpiresAt != 0 && now > token.ExpiresAt {
+		return types.ErrorInvalidToken
+	}
+
+	userObj, err := s.db.Filter(s.dbCtx, mongo.Users, bson.M{"_id": token.UserID}, false, types.ErrorInvalidToken)
+	if err != nil {
+		return err
+	}
+
+	var user mongo.UserObject
+	if err := mongo.ReadInto[mongo.UserObject](userObj, &user); err != nil {
+		return err
+	}
+
+	if err := accountStatus(&user); err != nil {
+		return err
+	}
+
+	granted := Permissions(&user)
+	scopes := []string{}
+	for _, v := range token.Scopes {
+		if utils.ArrayContains(granted, PermAll) || utils.ArrayContains(granted, v) {
+			scopes = append(scopes, v)
+		}
+	}
+
+	if _, err := s.db.Modify(s.dbCtx, mongo.APITokens, bson.M{"_id": token.ID}, bson.M{"$set": bson.M{"last_used": now}}); err != nil {
+		return err
+	}
+
+	claims := jwt.MapClaims{
+		"username":    user.Username,
+		"user_id":     user.ID.Hex(),
+		"role":        user.GetRole(),
+		"permissions": scopes,
+		"owner_id":    token.OwnerID.Hex(),
+		"exp":         time.Now().Add(time.Minute * time.Duration(types.Cfg.Crypto.AccessTokenExpiry)).Unix(),
+	}
+
+	c.Locals("user", &jwt.Token{Claims: claims, Valid: true})
+	return c.Next()
+}
+
+// CreateAPIToken creates a long-lived token for automation, the token is only returned once
+func (s *Server) CreateAPIToken(c fiber.Ctx) error {
+	var msg APITokenMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	if len(msg.Scopes) == 0 {
+		return types.ErrorInvalidScope
+	}
+
+	fields, err := s.parseJWTFields(c)
+	if err != nil {
+		return err
+	}
+
+	// A token can't do more than the user creating it
+	for _, v := range msg.Scopes {
+		if !utils.ArrayContains(permissions, v) || utils.ArrayContains(tokenScopeBlacklist, v) || !fields.Can(v) {
+			return types.ErrorInvalidScope
+		}
+	}
+
+	if msg.ExpiresAt != 0 && msg.ExpiresAt <= time.Now().Unix() {
+		return types.ErrorInvalidScope
+	}
+
+	owner, err := s.getOwner(msg.OwnerID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.verifyUser(c, owner); err != nil {
+		return err
+	}
+
+	user, err := s.getUser(fields.UserID)
+	if err != nil {
+		return err
+	}
+
+	raw, err := crypto.GenerateAPIKey(32)
+	if err != nil {
+		return err
+	}
+	plain := apiTokenPrefix + raw
+
+	token := &mongo.APITokenObject{
+		OwnerID:   owner.ID,
+		UserID:    user.ID,
+		Name:      msg.Name,
+		Token:     crypto.KeyedHash(plain, s.licensePepper),
+		Hint:      licenseHint(plain),
+		Scopes:    msg.Scopes,
+		ExpiresAt: msg.ExpiresAt,
+		CreatedAt: time.Now().Unix(),
+	}
+
+	item, err := s.db.CreateAndReturn(s.dbCtx, mongo.APITokens, token)
+	if err != nil {
+		return err
+	}
+
+	id, ok := item.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return fiber.ErrInternalServerError
+	}
+
+	returnDump := fiber.Map{"success": true, "id": id.Hex(), "token": plain, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// ListAPITokens returns the tokens of an owner without the tokens themselves
+func (s *Server) ListAPITokens(c fiber.Ctx) error {
+	var msg OwnerMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	owner, err := s.getOwner(msg.OwnerID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.verifyUser(c, owner); err != nil {
+		return err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	unparsed, err := s.db.Find(s.dbCtx, mongo.APITokens, bson.M{"owner_id": owner.ID}, opts)
+	if err != nil {
+		return err
+	}
+
+	var tokens []mongo.APITokenObject
+	if err := mongo.ReadAllInto[mongo.APITokenObject](unparsed, &tokens); err != nil {
+		return err
+	}
+
+	views := []fiber.Map{}
+	for _, v := range tokens {
+		views = append(views, fiber.Map{
+			"id":         v.ID.Hex(),
+			"name":       v.Name,
+			"hint":       v.Hint,
+			"user_id":    v.UserID.Hex(),
+			"scopes":     v.Scopes,
+			"expires_at": v.ExpiresAt,
+			"last_used":  v.LastUsed,
+			"created_at": v.CreatedAt,
+		})
+	}
+
+	returnDump := fiber.Map{"success": true, "tokens": views, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+func (s *Server) RevokeAPIToken(c fiber.Ctx) error {
+	var msg APITokenIDMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	owner, err := s.getOwner(msg.OwnerID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.verifyUser(c, owner); err != nil {
+		return err
+	}
+
+	tokenID, err := primitive.ObjectIDFromHex(msg.TokenID)
+	if err != nil {
+		return types.ErrorInvalidToken
+	}
+
+	if err := s.db.Delete(s.dbCtx, mongo.APITokens, bson.M{"_id": tokenID, "owner_id": owner.ID}); err != nil {
+		if err == types.ErrorNoMatches {
+			return types.ErrorInvalidToken
+		}
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
@@ -0,0 +1,326 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	crypto "github.com/Aran404/Goauth/internal/crypto"
+	mongo "github.com/Aran404/Goauth/internal/database/mongo"
+	types "github.com/Aran404/Goauth/internal/types"
+	"github.com/gofiber/fiber/v3"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	totpIssuer    = "Goauth"
+	recoveryCodes = 10
+)
+
+// Routes an account without two-factor can still reach while it is enforced
+var twoFactorSetup = []string{"/enroll-2fa", "/confirm-2fa", "/logout"}
+
+// sealSecret encrypts a TOTP secret at rest with a key derived from the pepper, the nonce is stored in front of it.
+func (s *Server) sealSecret(secret string) (string, error) {
+	nonce, err := crypto.GenerateNonce()
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := crypto.Encrypt([]byte(secret), sha256.Sum256(s.licensePepper), *nonce)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(nonce[:]) + ":" + sealed, nil
+}
+
+func (s *Server) openSecret(sealed string) (string, error) {
+	rawNonce, cipherText, ok := strings.Cut(sealed, ":")
+	if !ok {
+		return "", types.ErrorInvalidCode
+	}
+
+	nonce, err := hex.DecodeString(rawNonce)
+	if err != nil || len(nonce) != 12 {
+		return "", types.ErrorInvalidCode
+	}
+
+	secret, err := crypto.Decrypt(cipherText, sha256.Sum256(s.licensePepper), ([12]byte)(nonce))
+	if err != nil {
+		return "", err
+	}
+
+	return string(secret), nil
+}
+
+// normalizeRecoveryCode lets codes be typed with or without the dash and in any case.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
+
+// newRecoveryCodes returns the codes to show once and the keyed hashes to store.
+func (s *Server) newRecoveryCodes() ([]string, []string) {
+	plain, hashed := []string{}, []string{}
+	for i := 0; i < recoveryCodes; i++ {
+		code := hex.EncodeToString(crypto.GenerateRawKey(5))
+		plain = append(plain, code[:5]+"-"+code[5:])
+		hashed = append(hashed, crypto.KeyedHash(code, s.licensePepper))
+	}
+
+	return plain, hashed
+}
+
+// checkTwoFactorCode accepts a TOTP code or one of the recovery codes, either can only be used once.
+func (s *Server) checkTwoFactorCode(user *mongo.UserObject, code string) error {
+	if !user.TwoFactor.Enabled {
+		return types.ErrorInvalidCode
+	}
+
+	if len(code) != 6 {
+		query := bson.M{"_id": user.ID, "two_factor.recovery_codes": crypto.KeyedHash(normalizeRecoveryCode(code), s.licensePepper)}
+		update := bson.M{"$pull": bson.M{"two_factor.recovery_codes": query["two_factor.recovery_codes"]}}
+		matched, err := s.db.Modify(s.dbCtx, mongo.Users, query, update)
+		if err != nil {
+			return err
+		}
+
+		if matched == 0 {
+			return types.ErrorInvalidCode
+		}
+		return nil
+	}
+
+	secret, err := s.openSecret(user.TwoFactor.Secret)
+	if err != nil {
+		return err
+	}
+
+	counter, ok := crypto.ValidateTOTP(secret, code, time.Now(), 1)
+	if !ok {
+		return types.ErrorInvalidCode
+	}
+
+	// Moving the counter forward in the same update that checks it stops a code being replayed
+	query := bson.M{"_id": user.ID, "two_factor.last_counter": bson.M{"$lt": counter}}
+	matched, err := s.db.Modify(s.dbCtx, mongo.Users, query, bson.M{"$set": bson.M{"two_factor.last_counter": counter}})
+	if err != nil {
+		return err
+	}
+
+	if matched == 0 {
+		return types.ErrorInvalidCode
+	}
+
+	return nil
+}
+
+func loginTicketKey(ticket string) string {
+	return "login:" + ticket
+}
+
+// loginTicket remembers that a user passed the password step, the ticket is exchanged for tokens at /login-2fa.
+func (s *Server) loginTicket(user *mongo.UserObject) (string, error) {
+	ticket, err := crypto.GenerateAPIKey(32)
+	if err != nil {
+		return "", err
+	}
+
+	expiry := time.Duration(max(types.Cfg.Security.LoginTicketExpiry, 60)) * time.Second
+	if err := s.rdb.Set(s.dbCtx, loginTicketKey(ticket), user.ID.Hex(), expiry); err != nil {
+		return "", err
+	}
+
+	return ticket, nil
+}
+
+// LoginTwoFactor is the second login step, the ticket is used up even when the code is wrong
+func (s *Server) LoginTwoFactor(c fiber.Ctx) error {
+	var msg TwoFactorLoginMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	userID, err := s.rdb.GetDel(s.dbCtx, loginTicketKey(msg.Ticket))
+	if err != nil {
+		if err == types.ErrorNotFound {
+			return types.ErrorInvalidTicket
+		}
+		return err
+	}
+
+	user, err := s.getUser(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkCodeAttempt(user, msg.Code, c.IP()); err != nil {
+		return err
+	}
+
+	s.loginSucceeded(user.Username)
+	resp, err := s.startSession(c, user)
+	if err != nil {
+		return err
+	}
+
+	return s.EncryptJson(c, *resp, session)
+}
+
+// EnrollTwoFactor creates a secret to be confirmed with /confirm-2fa, enrolling again replaces an unconfirmed one
+func (s *Server) EnrollTwoFactor(c fiber.Ctx) error {
+	var msg PasswordMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkPasswordAttempt(user, msg.Password, c.IP()); err != nil {
+		return err
+	}
+
+	if user.TwoFactor.Enabled {
+		return types.ErrorTwoFactorEnabled
+	}
+
+	secret, err := crypto.GenerateTOTPSecret()
+	if err != nil {
+		return err
+	}
+
+	sealed, err := s.sealSecret(secret)
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.Update(s.dbCtx, mongo.Users, bson.M{"_id": user.ID}, bson.M{"two_factor.pending": sealed}); err != nil {
+		return err
+	}
+
+	returnDump := fiber.Map{
+		"success": true,
+		"secret":  secret,
+		"uri":     crypto.TOTPURI(totpIssuer, user.Username, secret),
+		"context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext),
+	}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// ConfirmTwoFactor enables two-factor once a code from the new secret is given.
+// The recovery codes are only returned here, along with new tokens that carry the two-factor claim.
+func (s *Server) ConfirmTwoFactor(c fiber.Ctx) error {
+	var msg CodeMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	if user.TwoFactor.Enabled {
+		return types.ErrorTwoFactorEnabled
+	}
+
+	if user.TwoFactor.Pending == "" {
+		return types.ErrorInvalidCode
+	}
+
+	secret, err := s.openSecret(user.TwoFactor.Pending)
+	if err != nil {
+		return err
+	}
+
+	counter, ok := crypto.ValidateTOTP(secret, msg.Code, time.Now(), 1)
+	if !ok {
+		return types.ErrorInvalidCode
+	}
+
+	plain, hashed := s.newRecoveryCodes()
+	user.TwoFactor = mongo.TwoFactorObject{
+		Enabled:       true,
+		Secret:        user.TwoFactor.Pending,
+		RecoveryCodes: hashed,
+		LastCounter:   counter,
+	}
+
+	if err := s.db.Update(s.dbCtx, mongo.Users, bson.M{"_id": user.ID}, bson.M{"two_factor": user.TwoFactor}); err != nil {
+		return err
+	}
+
+	resp, err := s.replaceSessions(c, user)
+	if err != nil {
+		return err
+	}
+	(*resp)["recovery_codes"] = plain
+
+	return s.EncryptJson(c, *resp, session)
+}
+
+// DisableTwoFactor needs the password and a code, it is refused while two-factor is enforced
+func (s *Server) DisableTwoFactor(c fiber.Ctx) error {
+	var msg TwoFactorMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	if types.Cfg.Security.EnforceTwoFactor {
+		return types.ErrorTwoFactorRequired
+	}
+
+	user, err := s.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkPasswordAttempt(user, msg.Password, c.IP()); err != nil {
+		return err
+	}
+
+	if err := s.checkCodeAttempt(user, msg.Code, c.IP()); err != nil {
+		return err
+	}
+
+	if err := s.db.Update(s.dbCtx, mongo.Users, bson.M{"_id": user.ID}, bson.M{"two_factor": mongo.TwoFactorObject{}}); err != nil {
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// RegenerateRecoveryCodes replaces every recovery code, the old ones stop working
+func (s *Server) RegenerateRecoveryCodes(c fiber.Ctx) error {
+	var msg CodeMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.currentUser(c)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkCodeAttempt(user, msg.Code, c.IP()); err != nil {
+		return err
+	}
+
+	plain, hashed := s.newRecoveryCodes()
+	if err := s.db.Update(s.dbCtx, mongo.Users, bson.M{"_id": user.ID}, bson.M{"two_factor.recovery_codes": hashed}); err != nil {
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "recovery_codes": plain, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
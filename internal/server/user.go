@@ -2,16 +2,28 @@ package server
 
 import (
 	"encoding/json"
+	"regexp"
+	"strings"
 	"time"
 
+	crypto "github.com/Aran404/Goauth/internal/crypto"
 	mongo "github.com/Aran404/Goauth/internal/database/mongo"
 	types "github.com/Aran404/Goauth/internal/types"
 	utils "github.com/Aran404/Goauth/internal/utils"
 	"github.com/gofiber/fiber/v3"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+const maxPageSize = 100
+
+var licenseSortFields = map[string]string{
+	"":        "_id",
+	"created": "_id",
+	"expiry":  "expiry",
+}
+
 func (s *Server) parseCreateAppBody(body []byte) (*mongo.OwnerObject, *NewApplicationMsg, error) {
 	var msg *NewApplicationMsg
 	if err := json.Unmarshal(body, &msg); err != nil {
@@ -65,19 +77,32 @@ func (s *Server) verifyAppInDatabase(msg *NewApplicationMsg, _id ...string) (*[]
 }
 
 func (s *Server) finalizeCreateApp(msg *NewApplicationMsg, owner *mongo.OwnerObject) (string, error) {
+	appID := primitive.NewObjectID()
+	if err := s.reserveApp(owner, appID); err != nil {
+		return "", err
+	}
+
+	policy := mongo.DefaultPolicy()
+	policy.AllowTrustOnFirstUse = msg.TrustOnFirstUse
+
 	dump := &mongo.ApplicationObject{
+		ID:       appID,
 		Name:     msg.Name,
 		OwnerID:  owner.ID,
 		Licenses: []primitive.ObjectID{},
-	}
-	id, err := s.db.CreateAndReturn(s.dbCtx, mongo.Applications, dump)
-	if err != nil {
+		Builds:   []mongo.BuildObject{},
+		Policy:   policy,
+		Status:   mongo.AppActive,
+	}
+	if err := s.db.Create(s.dbCtx, mongo.Applications, dump); err != nil {
+		// Give the slot back, the application was never created
+		if err := s.releaseApp(owner.ID, appID); err != nil {
+			return "", err
+		}
 		return "", err
 	}
 
-	proper := id.InsertedID.(primitive.ObjectID)
-	owner.Applications = append(owner.Applications, proper)
-	return proper.Hex(), s.db.Update(s.dbCtx, mongo.Owners, bson.M{"_id": owner.ID}, owner)
+	return appID.Hex(), nil
 }
 
 func (s *Server) parseCreateLicenseBody(body []byte) (*NewLicenseMsg, *[]primitive.M, error) {
@@ -86,10 +111,14 @@ func (s *Server) parseCreateLicenseBody(body []byte) (*NewLicenseMsg, *[]primiti
 		return nil, nil, err
 	}
 
-	if utils.CheckEmptyFields(msg, "Mask") {
+	if utils.CheckEmptyFields(msg, "Mask", "Notes", "Tier") {
 		return nil, nil, types.ErrorEmptyFields
 	}
 
+	if !validMetadata(msg.Metadata) {
+		return nil, nil, types.ErrorInvalidMetadata
+	}
+
 	owner, err := s.verifyAppInDatabase(&NewApplicationMsg{
 		OwnerID: msg.OwnerID,
 		Name:    msg.AppName,
@@ -101,7 +130,9 @@ func (s *Server) parseCreateLicenseBody(body []byte) (*NewLicenseMsg, *[]primiti
 	return msg, owner, nil
 }
 
-func (s *Server) verifyUser(c fiber.Ctx, owner *mongo.OwnerObject) error {
+// verifyUser makes sure the request may act on the owner, and on the given applications when there are any.
+// The permission itself is checked by the route, members are also held to the role they were invited with.
+func (s *Server) verifyUser(c fiber.Ctx, owner *mongo.OwnerObject, appIDs ...primitive.ObjectID) error {
 	fields, err := s.parseJWTFields(c)
 	if err != nil {
 		return err
@@ -111,23 +142,87 @@ func (s *Server) verifyUser(c fiber.Ctx, owner *mongo.OwnerObject) error {
 		return fiber.ErrUnauthorized
 	}
 
-	unparsed, err := s.db.Filter(s.dbCtx, mongo.Users, bson.M{"username": fields.Username}, false, types.ErrorUserNotFound)
+	if fields.OwnerID != "" && fields.OwnerID != owner.ID.Hex() {
+		return fiber.ErrUnauthorized
+	}
+
+	if fields.Global() {
+		return nil
+	}
+
+	user, err := s.getUser(fields.UserID)
 	if err != nil {
 		return err
 	}
 
-	var user mongo.UserObject
-	if err := mongo.ReadInto[mongo.UserObject](unparsed, &user); err != nil {
-		return err
+	if user.ID == owner.User {
+		return nil
 	}
 
-	if user.ID != owner.User {
+	member := owner.FindMember(user.ID)
+	if member == nil {
 		return fiber.ErrUnauthorized
 	}
 
+	permission, _ := c.Locals("permission").(string)
+	if permission != "" && !utils.ArrayContains(rolePermissions[member.Role], permission) {
+		return types.ErrorForbidden
+	}
+
+	// Members limited to some applications can't act on the owner as a whole
+	if len(member.AppIDs) > 0 {
+		if len(appIDs) == 0 {
+			return types.ErrorForbidden
+		}
+
+		for _, id := range appIDs {
+			if !mongo.CheckObjectArray(&member.AppIDs, id) {
+				return types.ErrorForbidden
+			}
+		}
+	}
+
 	return nil
 }
 
+// getUser looks a user up by the ID in their token, usernames can change while a token is still valid.
+func (s *Server) getUser(id string) (*mongo.UserObject, error) {
+	userID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, types.ErrorInvalidUserID
+	}
+
+	unparsed, err := s.db.Filter(s.dbCtx, mongo.Users, bson.M{"_id": userID}, false, types.ErrorUserNotFound)
+	if err != nil {
+		return nil, err
+	}
+
+	var user mongo.UserObject
+	if err := mongo.ReadInto[mongo.UserObject](unparsed, &user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// issueLicense generates a key for the license and stores it, the plain text key is returned to be shown once.
+func (s *Server) issueLicense(l *mongo.LicenseObject, appID string, settings utils.LicenseSettings) (string, error) {
+	settings.Salt = appID
+	licenseString := utils.CreateLicense(settings)
+
+	l.Key = crypto.KeyedHash(licenseString, s.licensePepper)
+	l.Hashed = true
+	l.Checksummed = true
+	// The checksum segment is derived from the rest of the key, the hint is taken from the random part instead
+	l.Hint = licenseHint(licenseString[:strings.LastIndex(licenseString, "-")])
+
+	if err := s.dumpLicense(l, appID); err != nil {
+		return "", err
+	}
+
+	return licenseString, nil
+}
+
 func (s *Server) dumpLicense(l *mongo.LicenseObject, appID string) error {
 	properID, err := primitive.ObjectIDFromHex(appID)
 	if err != nil {
@@ -155,8 +250,9 @@ func (s *Server) dumpLicense(l *mongo.LicenseObject, appID string) error {
 		return fiber.ErrInternalServerError
 	}
 
-	application.Licenses = append(application.Licenses, id)
-	return s.updateApplication(&application)
+	// Only the license list is touched, so a status or policy changed meanwhile is kept
+	_, err = s.db.Modify(s.dbCtx, mongo.Applications, bson.M{"_id": application.ID}, bson.M{"$addToSet": bson.M{"licenses": id}})
+	return err
 }
 
 // CreateApplication creates a new application and dumps it in the database
@@ -173,6 +269,10 @@ func (s *Server) CreateApplication(c fiber.Ctx) error {
 		return err
 	}
 
+	if err := s.verifyUser(c, owner); err != nil {
+		return err
+	}
+
 	id, err := s.finalizeCreateApp(msg, owner)
 	if err != nil {
 		return err
@@ -189,41 +289,385 @@ func (s *Server) CreateLicense(c fiber.Ctx) error {
 		return err
 	}
 
-	msg, unparsed, err := s.parseCreateLicenseBody(body)
+	msg, _, err := s.parseCreateLicenseBody(body)
 	if err != nil {
 		return err
 	}
 
-	var owner mongo.OwnerObject
-	if err := mongo.ReadInto[mongo.OwnerObject](*unparsed, &owner); err != nil {
+	// The application has to belong to the owner, its licenses are counted against that owner's plan
+	owner, _, err := s.authorizeApp(c, msg.OwnerID, msg.AppID)
+	if err != nil {
 		return err
 	}
 
-	// Now we must check if the request is authorized to do this action
-	if err := s.verifyUser(c, &owner); err != nil {
+	fields, err := s.parseJWTFields(c)
+	if err != nil {
 		return err
 	}
 
-	licenseString := utils.CreateLicense(utils.LicenseSettings{
+	creator, err := primitive.ObjectIDFromHex(fields.UserID)
+	if err != nil {
+		return types.ErrorInvalidUserID
+	}
+
+	if err := s.reserveLicenses(owner, 1); err != nil {
+		return err
+	}
+
+	license := &mongo.LicenseObject{
+		OwnerID:         owner.ID,
+		ExpectedExpiry:  msg.Expiry,
+		Metadata:        msg.Metadata,
+		VisibleMetadata: msg.VisibleMetadata,
+		Notes:           msg.Notes,
+		Tier:            msg.Tier,
+		CreatedBy:       creator,
+	}
+
+	licenseString, err := s.issueLicense(license, msg.AppID, utils.LicenseSettings{
 		Mask:          msg.Mask,
 		OnlyCapitals:  msg.OnlyCapitals,
 		OnlyLowercase: msg.OnlyLowercase,
 	})
-	license := &mongo.LicenseObject{
-		Key:            licenseString,
-		OwnerID:        owner.ID,
-		ExpectedExpiry: msg.Expiry,
+	if err != nil {
+		if err := s.releaseLicenses(owner.ID, 1); err != nil {
+			return err
+		}
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "key": licenseString, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// authorizeApp loads an owner and one of its applications, making sure the request is allowed to manage them.
+func (s *Server) authorizeApp(c fiber.Ctx, ownerID, appID string) (*mongo.OwnerObject, *mongo.ApplicationObject, error) {
+	owner, err := s.getOwner(ownerID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	app, err := s.getApplication(appID, owner)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// ! In production you must use a mutex to prevent data races
-	if err := s.dumpLicense(license, msg.AppID); err != nil {
+	if err := s.verifyUser(c, owner, app.ID); err != nil {
+		return nil, nil, err
+	}
+
+	return owner, app, nil
+}
+
+// UpdateLicense changes the metadata and notes of a license
+func (s *Server) UpdateLicense(c fiber.Ctx) error {
+	session, body, err := s.ParseBody(c)
+	if err != nil {
 		return err
 	}
 
-	returnDump := fiber.Map{"success": true, "key": licenseString, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	var msg *UpdateLicenseMsg
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return types.ErrorInvalidJSON
+	}
+
+	if utils.CheckEmptyFields(msg) {
+		return types.ErrorEmptyFields
+	}
+
+	if !validMetadata(msg.Metadata) {
+		return types.ErrorInvalidMetadata
+	}
+
+	_, app, err := s.authorizeApp(c, msg.OwnerID, msg.AppID)
+	if err != nil {
+		return err
+	}
+
+	license, err := s.getLicenseByID(msg.LicenseID, app)
+	if err != nil {
+		return err
+	}
+
+	// Only the fields that were sent are replaced
+	update := bson.M{}
+	if msg.Metadata != nil {
+		update["metadata"] = msg.Metadata
+	}
+
+	if msg.VisibleMetadata != nil {
+		update["visible_metadata"] = msg.VisibleMetadata
+	}
+
+	if msg.Notes != nil {
+		update["notes"] = *msg.Notes
+	}
+
+	if msg.Tier != nil {
+		update["tier"] = *msg.Tier
+	}
+
+	if len(update) == 0 {
+		return types.ErrorEmptyFields
+	}
+
+	if err := s.db.Update(s.dbCtx, mongo.Licenses, bson.M{"_id": license.ID}, update); err != nil {
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
 	return s.EncryptJson(c, returnDump, session)
 }
 
-// ! Probably won't do these anytime soon unless the project picks up some traction
-// TODO: Delete application
-// TODO: Delete specific license
+// RevokeLicense permanently stops a license from validating
+func (s *Server) RevokeLicense(c fiber.Ctx) error {
+	var msg LicenseIDMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	_, app, err := s.authorizeApp(c, msg.OwnerID, msg.AppID)
+	if err != nil {
+		return err
+	}
+
+	license, err := s.getLicenseByID(msg.LicenseID, app)
+	if err != nil {
+		return err
+	}
+
+	matched, err := s.db.Modify(s.dbCtx, mongo.Licenses, bson.M{"_id": license.ID, "revoked": bson.M{"$ne": true}}, bson.M{"$set": bson.M{"revoked": true}})
+	if err != nil {
+		return err
+	}
+
+	// Revoking twice is harmless but should only notify once
+	if matched > 0 {
+		license.Revoked = true
+		s.emitEvent(app.ID, EventLicenseRevoked, fiber.Map{"license": licenseView(license)})
+	}
+
+	returnDump := fiber.Map{"success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// SearchLicenses pages through the licenses of an application matching their metadata or notes
+func (s *Server) SearchLicenses(c fiber.Ctx) error {
+	session, body, err := s.ParseBody(c)
+	if err != nil {
+		return err
+	}
+
+	var msg *SearchLicensesMsg
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return types.ErrorInvalidJSON
+	}
+
+	if utils.CheckEmptyFields(msg, "Notes") {
+		return types.ErrorEmptyFields
+	}
+
+	if !validMetadata(msg.Metadata) {
+		return types.ErrorInvalidMetadata
+	}
+
+	_, app, err := s.authorizeApp(c, msg.OwnerID, msg.AppID)
+	if err != nil {
+		return err
+	}
+
+	query := bson.M{"app_id": app.ID}
+	metadataQuery(query, msg.Metadata)
+
+	if msg.Notes != "" {
+		query["notes"] = bson.M{"$regex": regexp.QuoteMeta(msg.Notes), "$options": "i"}
+	}
+
+	if msg.Page < 1 {
+		msg.Page = 1
+	}
+
+	if msg.Limit < 1 || msg.Limit > maxPageSize {
+		msg.Limit = maxPageSize
+	}
+
+	total, err := s.db.Count(s.dbCtx, mongo.Licenses, query)
+	if err != nil {
+		return err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "_id", Value: 1}}).
+		SetSkip((msg.Page - 1) * msg.Limit).
+		SetLimit(msg.Limit)
+
+	licenses, err := s.findLicenses(query, opts)
+	if err != nil {
+		return err
+	}
+
+	returnDump := fiber.Map{
+		"success":  true,
+		"licenses": licenses,
+		"total":    total,
+		"page":     msg.Page,
+		"limit":    msg.Limit,
+		"context":  time.Now().Unix() + int64(types.Cfg.Security.AllowedContext),
+	}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// ListLicenses pages through the licenses of an application
+func (s *Server) ListLicenses(c fiber.Ctx) error {
+	session, body, err := s.ParseBody(c)
+	if err != nil {
+		return err
+	}
+
+	var msg *ListLicensesMsg
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return types.ErrorInvalidJSON
+	}
+
+	if utils.CheckEmptyFields(msg, "Status", "Fingerprint", "ResellerID", "Sort") {
+		return types.ErrorEmptyFields
+	}
+
+	if !validMetadata(msg.Metadata) {
+		return types.ErrorInvalidMetadata
+	}
+
+	_, app, err := s.authorizeApp(c, msg.OwnerID, msg.AppID)
+	if err != nil {
+		return err
+	}
+
+	query, err := listLicensesQuery(msg, app)
+	if err != nil {
+		return err
+	}
+
+	sortField, ok := licenseSortFields[msg.Sort]
+	if !ok {
+		return types.ErrorInvalidFilter
+	}
+
+	order := 1
+	if msg.Descending {
+		order = -1
+	}
+
+	if msg.Page < 1 {
+		msg.Page = 1
+	}
+
+	if msg.Limit < 1 || msg.Limit > maxPageSize {
+		msg.Limit = maxPageSize
+	}
+
+	total, err := s.db.Count(s.dbCtx, mongo.Licenses, query)
+	if err != nil {
+		return err
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: sortField, Value: order}, {Key: "_id", Value: order}}).
+		SetSkip((msg.Page - 1) * msg.Limit).
+		SetLimit(msg.Limit)
+
+	licenses, err := s.findLicenses(query, opts)
+	if err != nil {
+		return err
+	}
+
+	returnDump := fiber.Map{
+		"success":  true,
+		"licenses": licenses,
+		"total":    total,
+		"page":     msg.Page,
+		"limit":    msg.Limit,
+		"context":  time.Now().Unix() + int64(types.Cfg.Security.AllowedContext),
+	}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+func listLicensesQuery(msg *ListLicensesMsg, app *mongo.ApplicationObject) (bson.M, error) {
+	now := uint64(time.Now().Unix())
+	query := bson.M{"app_id": app.ID}
+	metadataQuery(query, msg.Metadata)
+
+	expiry := bson.M{}
+	switch msg.Status {
+	case "":
+	case "active":
+		expiry["$gt"] = now
+		query["revoked"] = bson.M{"$ne": true}
+	case "expired":
+		expiry["$lte"] = now
+	case "revoked":
+		query["revoked"] = true
+	default:
+		return nil, types.ErrorInvalidFilter
+	}
+
+	if msg.ExpiringBefore > 0 {
+		expiry["$lt"] = msg.ExpiringBefore
+	}
+
+	if msg.Activated != nil {
+		if *msg.Activated {
+			expiry["$ne"] = nil
+		} else if len(expiry) > 0 {
+			// An unactivated license has no expiry, so it can't match any expiry filter
+			return nil, types.ErrorInvalidFilter
+		} else {
+			query["expiry"] = nil
+		}
+	}
+
+	if len(expiry) > 0 {
+		query["expiry"] = expiry
+	}
+
+	// Every bound device is in devices, licenses bound before it existed only have fingerprint
+	if msg.Fingerprint != "" {
+		query["$or"] = bson.A{bson.M{"devices": msg.Fingerprint}, bson.M{"fingerprint": msg.Fingerprint}}
+	}
+
+	if msg.ResellerID != "" {
+		resellerID, err := primitive.ObjectIDFromHex(msg.ResellerID)
+		if err != nil {
+			return nil, types.ErrorInvalidFilter
+		}
+		query["reseller_id"] = resellerID
+	}
+
+	return query, nil
+}
+
+func metadataQuery(query bson.M, m map[string]string) {
+	for k, v := range m {
+		query["metadata."+k] = v
+	}
+}
+
+func (s *Server) findLicenses(query bson.M, opts ...*options.FindOptions) ([]fiber.Map, error) {
+	views := []fiber.Map{}
+
+	unparsed, err := s.db.Find(s.dbCtx, mongo.Licenses, query, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var licenses []mongo.LicenseObject
+	if err := mongo.ReadAllInto[mongo.LicenseObject](unparsed, &licenses); err != nil {
+		return nil, err
+	}
+
+	for i := range licenses {
+		views = append(views, licenseView(&licenses[i]))
+	}
+
+	return views, nil
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"regexp"
+	"time"
+
+	mongo "github.com/Aran404/Goauth/internal/database/mongo"
+	types "github.com/Aran404/Goauth/internal/types"
+	utils "github.com/Aran404/Goauth/internal/utils"
+	"github.com/gofiber/fiber/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var variableName = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+// validatedKey marks a session that has validated a license, it holds the license's ID.
+func validatedKey(sessionID string) string {
+	return "validated:" + sessionID
+}
+
+// GetVariable returns one of the application's variables to a session that has validated a license.
+// The license is read again so revoking or disabling takes effect for sessions that are already open.
+func (s *Server) GetVariable(c fiber.Ctx) error {
+	var msg FetchVariableMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	raw, err := s.rdb.Get(s.dbCtx, validatedKey(c.Get("X-Session-Id")))
+	if err != nil {
+		if err == types.ErrorNotFound {
+			return types.ErrorNotValidated
+		}
+		return err
+	}
+
+	licenseID, err := primitive.ObjectIDFromHex(raw)
+	if err != nil {
+		return types.ErrorNotValidated
+	}
+
+	licenseObj, err := s.db.Filter(s.dbCtx, mongo.Licenses, bson.M{"_id": licenseID}, false, types.ErrorNotValidated)
+	if err != nil {
+		return err
+	}
+
+	var license mongo.LicenseObject
+	if err := mongo.ReadInto[mongo.LicenseObject](licenseObj, &license); err != nil {
+		return err
+	}
+
+	if license.Revoked {
+		return types.ErrorRevokedLicense
+	}
+
+	if license.Expiry == nil || uint64(time.Now().Unix()) > *license.Expiry {
+		return types.ErrorExpiredLicense
+	}
+
+	appObj, err := s.db.Filter(s.dbCtx, mongo.Applications, bson.M{"_id": license.Application}, false, types.ErrorInvalidApp)
+	if err != nil {
+		return err
+	}
+
+	var app mongo.ApplicationObject
+	if err := mongo.ReadInto[mongo.ApplicationObject](appObj, &app); err != nil {
+		return err
+	}
+
+	if err := checkAppStatus(&app); err != nil {
+		return err
+	}
+
+	variableObj, err := s.db.Filter(s.dbCtx, mongo.Variables, bson.M{"app_id": app.ID, "name": msg.Name}, false, types.ErrorInvalidVariable)
+	if err != nil {
+		return err
+	}
+
+	var variable mongo.VariableObject
+	if err := mongo.ReadInto[mongo.VariableObject](variableObj, &variable); err != nil {
+		return err
+	}
+
+	// Restricted variables look the same as missing ones to other tiers
+	if len(variable.Tiers) > 0 && !utils.ArrayContains(variable.Tiers, license.Tier) {
+		return types.ErrorInvalidVariable
+	}
+
+	returnDump := fiber.Map{"success": true, "value": variable.Value, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// SetVariable creates or replaces a variable, no tiers means every license may read it
+func (s *Server) SetVariable(c fiber.Ctx) error {
+	var msg VariableMsg
+	session, err := s.parseAppBody(c, &msg, "Value")
+	if err != nil {
+		return err
+	}
+
+	if !variableName.MatchString(msg.Name) {
+		return types.ErrorInvalidVariable
+	}
+
+	_, app, err := s.authorizeApp(c, msg.OwnerID, msg.AppID)
+	if err != nil {
+		return err
+	}
+
+	if msg.Tiers == nil {
+		msg.Tiers = []string{}
+	}
+
+	// One upsert, so concurrent sets of the same name can't both create it
+	query := bson.M{"app_id": app.ID, "name": msg.Name}
+	update := bson.M{"$set": bson.M{"value": msg.Value, "tiers": msg.Tiers, "updated_at": time.Now().Unix()}}
+	if err := s.db.Upsert(s.dbCtx, mongo.Variables, query, update); err != nil {
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+func (s *Server) DeleteVariable(c fiber.Ctx) error {
+	var msg VariableNameMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	_, app, err := s.authorizeApp(c, msg.OwnerID, msg.AppID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.Delete(s.dbCtx, mongo.Variables, bson.M{"app_id": app.ID, "name": msg.Name}); err != nil {
+		if err == types.ErrorNoMatches {
+			return types.ErrorInvalidVariable
+		}
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// ListVariables returns every variable of an application, values included
+func (s *Server) ListVariables(c fiber.Ctx) error {
+	var msg AppMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	_, app, err := s.authorizeApp(c, msg.OwnerID, msg.AppID)
+	if err != nil {
+		return err
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}})
+	unparsed, err := s.db.Find(s.dbCtx, mongo.Variables, bson.M{"app_id": app.ID}, opts)
+	if err != nil {
+		return err
+	}
+
+	variables := []mongo.VariableObject{}
+	if err := mongo.ReadAllInto[mongo.VariableObject](unparsed, &variables); err != nil {
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "variables": variables, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
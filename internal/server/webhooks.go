@@ -0,0 +1,500 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	crypto "github.com/Aran404/Goauth/internal/crypto"
+	mongo "github.com/Aran404/Goauth/internal/database/mongo"
+	log "github.com/Aran404/Goauth/internal/logger"
+	types "github.com/Aran404/Goauth/internal/types"
+	utils "github.com/Aran404/Goauth/internal/utils"
+	"github.com/gofiber/fiber/v3"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	EventLicenseActivated = "license.activated"
+	EventValidationFailed = "license.validation_failed"
+	EventLicenseExpired   = "license.expired"
+	EventLicenseRevoked   = "license.revoked"
+)
+
+var webhookEvents = []string{EventLicenseActivated, EventValidationFailed, EventLicenseExpired, EventLicenseRevoked}
+
+// emitEvent queues a delivery for every webhook of the application subscribed to the event.
+// Failures are only logged, a webhook should never break the request that triggered it.
+func (s *Server) emitEvent(appID primitive.ObjectID, event string, data fiber.Map) {
+	query := bson.M{"app_id": appID, "$or": bson.A{bson.M{"events": event}, bson.M{"events": bson.M{"$size": 0}}}}
+	unparsed, err := s.db.Find(s.dbCtx, mongo.Webhooks, query)
+	if err != nil {
+		log.Error(log.GetStackTrace(), "Could not find webhooks, Error: %v", err.Error())
+		return
+	}
+
+	var hooks []mongo.WebhookObject
+	if err := mongo.ReadAllInto[mongo.WebhookObject](unparsed, &hooks); err != nil {
+		log.Error(log.GetStackTrace(), "Could not read webhooks, Error: %v", err.Error())
+		return
+	}
+
+	now := time.Now().Unix()
+	for _, hook := range hooks {
+		payload, err := json.Marshal(fiber.Map{"event": event, "app_id": appID.Hex(), "created_at": now, "data": data})
+		if err != nil {
+			log.Error(log.GetStackTrace(), "Could not marshal webhook payload, Error: %v", err.Error())
+			return
+		}
+
+		delivery := &mongo.DeliveryObject{
+			WebhookID:   hook.ID,
+			AppID:       appID,
+			Event:       event,
+			Payload:     string(payload),
+			Status:      mongo.DeliveryPending,
+			NextAttempt: now,
+			CreatedAt:   now,
+		}
+
+		if err := s.db.Create(s.dbCtx, mongo.WebhookDeliveries, delivery); err != nil {
+			log.Error(log.GetStackTrace(), "Could not queue webhook delivery, Error: %v", err.Error())
+		}
+	}
+}
+
+// recordFailure counts failed validations of a license and notifies owners once the threshold is crossed.
+func (s *Server) recordFailure(holder *LicenseHolders, reason error) {
+	threshold := types.Cfg.Webhooks.FailureThreshold
+	if threshold <= 0 {
+		return
+	}
+
+	window := time.Duration(types.Cfg.Webhooks.FailureWindow) * time.Second
+	count, err := s.rdb.Incr(s.dbCtx, "failures:"+holder.license.ID.Hex(), window)
+	if err != nil {
+		log.Error(log.GetStackTrace(), "Could not count validation failure, Error: %v", err.Error())
+		return
+	}
+
+	// Only the request crossing the threshold notifies, so a flood sends a single event per window
+	if count == threshold {
+		s.emitEvent(holder.app.ID, EventValidationFailed, fiber.Map{
+			"license":  licenseView(holder.license),
+			"failures": count,
+			"reason":   types.ProperError(reason),
+		})
+	}
+}
+
+// RunWebhookWorker delivers queued webhook events until the server context is done.
+// It also notifies owners about licenses that expired since the last run.
+func (s *Server) RunWebhookWorker() {
+	for {
+		interval := time.Duration(max(types.Cfg.Webhooks.PollInterval, 1)) * time.Second
+		select {
+		case <-s.dbCtx.Done():
+			return
+		case <-time.After(interval):
+		}
+
+		s.sweepExpiredLicenses()
+		s.deliverPending()
+	}
+}
+
+func (s *Server) sweepExpiredLicenses() {
+	query := bson.M{"expiry": bson.M{"$ne": nil, "$lte": uint64(time.Now().Unix())}, "expiry_notified": bson.M{"$ne": true}}
+	unparsed, err := s.db.Find(s.dbCtx, mongo.Licenses, query, options.Find().SetLimit(100))
+	if err != nil {
+		log.Error(log.GetStackTrace(), "Could not find expired licenses, Error: %v", err.Error())
+		return
+	}
+
+	var licenses []mongo.LicenseObject
+	if err := mongo.ReadAllInto[mongo.LicenseObject](unparsed, &licenses); err != nil {
+		log.Error(log.GetStackTrace(), "Could not read expired licenses, Error: %v", err.Error())
+		return
+	}
+
+	for i := range licenses {
+		l := &licenses[i]
+
+		// Marking first means another replica sweeping at the same time won't notify twice
+		matched, err := s.db.Modify(s.dbCtx, mongo.Licenses, bson.M{"_id": l.ID, "expiry_notified": bson.M{"$ne": true}}, bson.M{"$set": bson.M{"expiry_notified": true}})
+		if err != nil || matched == 0 {
+			continue
+		}
+
+		s.emitEvent(l.Application, EventLicenseExpired, fiber.Map{"license": licenseView(l)})
+	}
+}
+
+func (s *Server) deliverPending() {
+	now := time.Now().Unix()
+	query := bson.M{"status": mongo.DeliveryPending, "next_attempt": bson.M{"$lte": now}}
+	opts := options.Find().SetSort(bson.D{{Key: "next_attempt", Value: 1}}).SetLimit(50)
+
+	unparsed, err := s.db.Find(s.dbCtx, mongo.WebhookDeliveries, query, opts)
+	if err != nil {
+		log.Error(log.GetStackTrace(), "Could not find pending deliveries, Error: %v", err.Error())
+		return
+	}
+
+	var deliveries []mongo.DeliveryObject
+	if err := mongo.ReadAllInto[mongo.DeliveryObject](unparsed, &deliveries); err != nil {
+		log.Error(log.GetStackTrace(), "Could not read pending deliveries, Error: %v", err.Error())
+		return
+	}
+
+	for i := range deliveries {
+		d := &deliveries[i]
+
+		// Leasing the delivery keeps other replicas from sending it while this one is
+		lease := bson.M{"$set": bson.M{"next_attempt": now + 2*max(types.Cfg.Webhooks.Timeout, 1)}}
+		matched, err := s.db.Modify(s.dbCtx, mongo.WebhookDeliveries, bson.M{"_id": d.ID, "status": mongo.DeliveryPending, "next_attempt": d.NextAttempt}, lease)
+		if err != nil || matched == 0 {
+			continue
+		}
+
+		s.deliver(d)
+	}
+}
+
+func (s *Server) deliver(d *mongo.DeliveryObject) {
+	d.Attempts++
+	code, err := s.sendWebhook(d)
+
+	if err := s.db.Update(s.dbCtx, mongo.WebhookDeliveries, bson.M{"_id": d.ID}, DeliveryUpdate(d, code, err)); err != nil {
+		log.Error(log.GetStackTrace(), "Could not update webhook delivery, Error: %v", err.Error())
+	}
+}
+
+// DeliveryUpdate records an attempt, failures are retried with an exponential backoff until max_attempts sends them to the dead-letter list.
+func DeliveryUpdate(d *mongo.DeliveryObject, code int, err error) bson.M {
+	update := bson.M{"attempts": d.Attempts, "response_code": code}
+
+	switch {
+	case err == nil:
+		update["status"] = mongo.DeliveryDelivered
+		update["delivered_at"] = time.Now().Unix()
+		update["last_error"] = ""
+	case d.Attempts >= types.Cfg.Webhooks.MaxAttempts:
+		// Dead deliveries stay around so owners can inspect and retry them
+		update["status"] = mongo.DeliveryDead
+		update["last_error"] = err.Error()
+	default:
+		backoff := types.Cfg.Webhooks.Backoff << (d.Attempts - 1)
+		update["next_attempt"] = time.Now().Unix() + backoff
+		update["last_error"] = err.Error()
+	}
+
+	return update
+}
+
+func (s *Server) sendWebhook(d *mongo.DeliveryObject) (int, error) {
+	unparsed, err := s.db.Filter(s.dbCtx, mongo.Webhooks, bson.M{"_id": d.WebhookID}, false, types.ErrorInvalidWebhook)
+	if err != nil {
+		return 0, err
+	}
+
+	var hook mongo.WebhookObject
+	if err := mongo.ReadInto[mongo.WebhookObject](unparsed, &hook); err != nil {
+		return 0, err
+	}
+
+	return SendWebhook(webhookClient(), &hook, d)
+}
+
+// SendWebhook posts a delivery to its webhook, a response outside 2xx is an error.
+func SendWebhook(client *http.Client, hook *mongo.WebhookObject, d *mongo.DeliveryObject) (int, error) {
+	req, err := http.NewRequest("POST", hook.URL, strings.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Goauth-Event", d.Event)
+	req.Header.Set("X-Goauth-Delivery", d.ID.Hex())
+	req.Header.Set("X-Goauth-Timestamp", fmt.Sprint(timestamp))
+	req.Header.Set("X-Goauth-Signature", SignWebhook(hook.Secret, timestamp, d.Payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with status %v", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+var errPrivateAddress = errors.New("webhook resolves to a private or local address")
+
+// publicIP reports whether an address is outside the server's own networks.
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified())
+}
+
+// checkWebhookURL resolves the host of a webhook so it can't be pointed at the server's own network.
+func checkWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return types.ErrorInvalidWebhook
+	}
+
+	if types.Cfg.Webhooks.AllowPrivateURLs {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip", u.Hostname())
+	if err != nil || len(ips) == 0 {
+		return types.WithMessage(types.ErrorInvalidWebhook, "Webhook host could not be resolved.")
+	}
+
+	for _, ip := range ips {
+		if !publicIP(ip) {
+			return types.WithMessage(types.ErrorInvalidWebhook, "Webhooks can't point to private or local addresses.")
+		}
+	}
+
+	return nil
+}
+
+// webhookClient checks every address it connects to, so a host that resolves differently after registration is still refused.
+func webhookClient() *http.Client {
+	dialer := &net.Dialer{Timeout: time.Duration(types.Cfg.Webhooks.Timeout) * time.Second}
+	if !types.Cfg.Webhooks.AllowPrivateURLs {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return errPrivateAddress
+			}
+			return nil
+		}
+	}
+
+	return &http.Client{
+		Timeout:   time.Duration(types.Cfg.Webhooks.Timeout) * time.Second,
+		Transport: &http.Transport{DialContext: dialer.DialContext, DisableKeepAlives: true},
+	}
+}
+
+// SignWebhook signs a payload the same way receivers should to verify X-Goauth-Signature.
+func SignWebhook(secret string, timestamp int64, payload string) string {
+	return crypto.KeyedHash(fmt.Sprintf("%d.%s", timestamp, payload), []byte(secret))
+}
+
+// CreateWebhook registers a URL to be notified about license events
+func (s *Server) CreateWebhook(c fiber.Ctx) error {
+	var msg WebhookMsg
+	session, err := s.parseAppBody(c, &msg, "Secret")
+	if err != nil {
+		return err
+	}
+
+	if err := checkWebhookURL(msg.URL); err != nil {
+		return err
+	}
+
+	for _, v := range msg.Events {
+		if !utils.ArrayContains(webhookEvents, v) {
+			return types.ErrorInvalidWebhook
+		}
+	}
+
+	_, app, err := s.authorizeApp(c, msg.OwnerID, msg.AppID)
+	if err != nil {
+		return err
+	}
+
+	if msg.Secret == "" {
+		if msg.Secret, err = crypto.GenerateAPIKey(32); err != nil {
+			return err
+		}
+	}
+
+	events := msg.Events
+	if events == nil {
+		events = []string{}
+	}
+
+	hook := &mongo.WebhookObject{
+		OwnerID:   app.OwnerID,
+		AppID:     app.ID,
+		URL:       msg.URL,
+		Secret:    msg.Secret,
+		Events:    events,
+		CreatedAt: time.Now().Unix(),
+	}
+
+	item, err := s.db.CreateAndReturn(s.dbCtx, mongo.Webhooks, hook)
+	if err != nil {
+		return err
+	}
+
+	id, ok := item.InsertedID.(primitive.ObjectID)
+	if !ok {
+		return fiber.ErrInternalServerError
+	}
+
+	returnDump := fiber.Map{"success": true, "id": id.Hex(), "secret": msg.Secret, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// DeleteWebhook stops notifying a URL, queued deliveries for it are dropped
+func (s *Server) DeleteWebhook(c fiber.Ctx) error {
+	var msg WebhookIDMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	_, app, err := s.authorizeApp(c, msg.OwnerID, msg.AppID)
+	if err != nil {
+		return err
+	}
+
+	hookID, err := primitive.ObjectIDFromHex(msg.WebhookID)
+	if err != nil {
+		return types.ErrorInvalidWebhook
+	}
+
+	if err := s.db.Delete(s.dbCtx, mongo.Webhooks, bson.M{"_id": hookID, "app_id": app.ID}); err != nil {
+		if err == types.ErrorNoMatches {
+			return types.ErrorInvalidWebhook
+		}
+		return err
+	}
+
+	// Having nothing queued is fine
+	if err := s.db.Delete(s.dbCtx, mongo.WebhookDeliveries, bson.M{"webhook_id": hookID, "status": mongo.DeliveryPending}); err != nil && err != types.ErrorNoMatches {
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// ListWebhooks returns the webhooks of an application, secrets are only shown on creation
+func (s *Server) ListWebhooks(c fiber.Ctx) error {
+	var msg AppMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	_, app, err := s.authorizeApp(c, msg.OwnerID, msg.AppID)
+	if err != nil {
+		return err
+	}
+
+	unparsed, err := s.db.Find(s.dbCtx, mongo.Webhooks, bson.M{"app_id": app.ID})
+	if err != nil {
+		return err
+	}
+
+	var hooks []mongo.WebhookObject
+	if err := mongo.ReadAllInto[mongo.WebhookObject](unparsed, &hooks); err != nil {
+		return err
+	}
+
+	views := []fiber.Map{}
+	for _, v := range hooks {
+		views = append(views, fiber.Map{"id": v.ID.Hex(), "url": v.URL, "events": v.Events, "created_at": v.CreatedAt})
+	}
+
+	returnDump := fiber.Map{"success": true, "webhooks": views, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// ListWebhookDeliveries returns the most recent deliveries, filter by the dead status for the dead-letter list
+func (s *Server) ListWebhookDeliveries(c fiber.Ctx) error {
+	var msg DeliveriesMsg
+	session, err := s.parseAppBody(c, &msg, "Status")
+	if err != nil {
+		return err
+	}
+
+	_, app, err := s.authorizeApp(c, msg.OwnerID, msg.AppID)
+	if err != nil {
+		return err
+	}
+
+	query := bson.M{"app_id": app.ID}
+	if msg.Status != "" {
+		query["status"] = msg.Status
+	}
+
+	if msg.Limit < 1 || msg.Limit > maxPageSize {
+		msg.Limit = maxPageSize
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(msg.Limit)
+	unparsed, err := s.db.Find(s.dbCtx, mongo.WebhookDeliveries, query, opts)
+	if err != nil {
+		return err
+	}
+
+	deliveries := []mongo.DeliveryObject{}
+	if err := mongo.ReadAllInto[mongo.DeliveryObject](unparsed, &deliveries); err != nil {
+		return err
+	}
+
+	returnDump := fiber.Map{"success": true, "deliveries": deliveries, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
+
+// RetryWebhookDelivery moves a dead delivery back into the queue
+func (s *Server) RetryWebhookDelivery(c fiber.Ctx) error {
+	var msg DeliveryIDMsg
+	session, err := s.parseAppBody(c, &msg)
+	if err != nil {
+		return err
+	}
+
+	_, app, err := s.authorizeApp(c, msg.OwnerID, msg.AppID)
+	if err != nil {
+		return err
+	}
+
+	deliveryID, err := primitive.ObjectIDFromHex(msg.DeliveryID)
+	if err != nil {
+		return types.ErrorNotFound
+	}
+
+	query := bson.M{"_id": deliveryID, "app_id": app.ID, "status": mongo.DeliveryDead}
+	update := bson.M{"$set": bson.M{"status": mongo.DeliveryPending, "attempts": 0, "next_attempt": time.Now().Unix()}}
+	matched, err := s.db.Modify(s.dbCtx, mongo.WebhookDeliveries, query, update)
+	if err != nil {
+		return err
+	}
+
+	if matched == 0 {
+		return types.ErrorNotFound
+	}
+
+	returnDump := fiber.Map{"success": true, "context": time.Now().Unix() + int64(types.Cfg.Security.AllowedContext)}
+	return s.EncryptJson(c, returnDump, session)
+}
@@ -35,19 +35,87 @@ var (
 
 	// Validation Errors
 	ErrorInvalidLicense     = errors.New("invalid license")
+	ErrorMalformedLicense   = errors.New("malformed license")
+	ErrorInvalidMetadata    = errors.New("invalid metadata")
+	ErrorInvalidFilter      = errors.New("invalid filter")
 	ErrorExpiredLicense     = errors.New("license expired")
 	ErrorInvalidFingerprint = errors.New("invalid fingerprint")
 	ErrorInsecurePassword   = errors.New("insecure password")
 	ErrorIncorrectLength    = errors.New("incorrect length")
+	ErrorInvalidUsername    = errors.New("invalid username")
 
 	// Security Errors
 	ErrorNoIntegrity      = errors.New("no integrity")
+	ErrorInvalidBuild     = errors.New("invalid build")
+	ErrorBlockedBuild     = errors.New("blocked build")
+	ErrorBuildExists      = errors.New("build already exists")
+	ErrorInvalidHash      = errors.New("invalid hash")
+	ErrorInvalidStatus    = errors.New("invalid status")
 	ErrorInvalidIntegrity = errors.New("invalid integrity")
 	ErrorContextExpired   = errors.New("context expired")
 
 	// User Errors
 	ErrorEmptyStruct = errors.New("empty struct")
 
+	// Application Errors
+	ErrorApplicationDisabled    = errors.New("application disabled")
+	ErrorApplicationMaintenance = errors.New("application under maintenance")
+	ErrorApplicationFrozen      = errors.New("application frozen")
+	ErrorInvalidPolicy          = errors.New("invalid policy")
+	ErrorIPLimit                = errors.New("ip limit reached")
+	ErrorRevokedLicense         = errors.New("license revoked")
+	ErrorInvalidWebhook         = errors.New("invalid webhook")
+	ErrorInvalidVariable        = errors.New("invalid variable")
+	ErrorNotValidated           = errors.New("session not validated")
+	ErrorInvalidArchive         = errors.New("invalid archive")
+	ErrorArchiveExpired         = errors.New("archive expired")
+	ErrorArchiveParent          = errors.New("archive parent missing")
+
+	// Access Errors
+	ErrorForbidden     = errors.New("forbidden")
+	ErrorInvalidRole   = errors.New("invalid role")
+	ErrorInvalidInvite = errors.New("invalid invitation")
+	ErrorIsMember      = errors.New("already a member")
+	ErrorInvalidToken  = errors.New("invalid api token")
+	ErrorInvalidScope  = errors.New("invalid scope")
+
+	// Reseller Errors
+	ErrorInvalidReseller = errors.New("invalid reseller")
+	ErrorInvalidTemplate = errors.New("invalid template")
+	ErrorQuotaExceeded   = errors.New("quota exceeded")
+
+	// Plan Errors
+	ErrorInvalidPlan      = errors.New("invalid plan")
+	ErrorPlanApplications = errors.New("application limit reached")
+	ErrorPlanLicenses     = errors.New("license limit reached")
+	ErrorPlanDevices      = errors.New("device limit reached")
+
+	// Account Errors
+	ErrorAccountInUse       = errors.New("account in use")
+	ErrorInvalidCredentials = errors.New("invalid credentials")
+	ErrorTooManyAttempts    = errors.New("too many attempts")
+	ErrorServerBusy         = errors.New("server busy")
+	ErrorAccountSuspended   = errors.New("account suspended")
+	ErrorAccountExpired     = errors.New("account expired")
+	ErrorSessionNotFound    = errors.New("session not found")
+
+	// Two Factor Errors
+	ErrorInvalidCode       = errors.New("invalid two-factor code")
+	ErrorInvalidTicket     = errors.New("invalid login ticket")
+	ErrorTwoFactorRequired = errors.New("two-factor required")
+	ErrorTwoFactorEnabled  = errors.New("two-factor already enabled")
+
+	// Access Rule Errors
+	ErrorInvalidRule  = errors.New("invalid access rule")
+	ErrorAccessDenied = errors.New("access denied")
+
+	// Email Errors
+	ErrorInvalidEmail      = errors.New("invalid email")
+	ErrorEmailExists       = errors.New("email already in use")
+	ErrorEmailVerified     = errors.New("email already verified")
+	ErrorNoEmail           = errors.New("no email")
+	ErrorInvalidEmailToken = errors.New("invalid email token")
+
 	// Proper Errors
 	properErrors = map[error]string{
 		ErrorInvalidHello:       "Improper Hello Payload.",
@@ -60,18 +128,27 @@ var (
 		ErrorEmptyFields:        "One or more fields are empty.",
 		ErrorOwnerNotFound:      "OwnerID not found in database.",
 		ErrorInvalidLicense:     "Invalid license key.",
+		ErrorMalformedLicense:   "License key is malformed. Please check it for typos.",
+		ErrorInvalidMetadata:    "Metadata keys must not be empty, contain dots or start with '$'.",
+		ErrorInvalidFilter:      "Invalid filter, status or sort option.",
 		ErrorExpiredLicense:     "License key has expired.",
-		ErrorInvalidFingerprint: "Authority fingerprint is invalid. You may only use a license on one device.",
+		ErrorInvalidFingerprint: "Authority fingerprint is invalid. This license is already in use on the maximum number of devices.",
 		ErrorNoSession:          "No sessions found. Please create one.",
 		ErrorNoIntegrity:        "No integrity signature found. Could be an attacker.",
 		ErrorInvalidIntegrity:   "Integrity signature is invalid. Could be an attacker.",
+		ErrorInvalidBuild:       "This build of the application is not recognised.",
+		ErrorBlockedBuild:       "This build of the application has been blocked. Please update.",
+		ErrorBuildExists:        "A build with this hash is already registered.",
+		ErrorInvalidHash:        "Build hash must be a hex encoded SHA-256 digest.",
+		ErrorInvalidStatus:      "Invalid status.",
 		ErrorContextExpired:     "Context window has passed.",
 		ErrorCannotDecrypt:      "Could not decrypt. Please verify encryption.",
 		ErrorInvalidOwner:       "Invalid Owner ID.",
 		ErrorInvalidApp:         "Invalid Application ID.",
 		ErrorEmptyStruct:        "Empty data.",
 		ErrorInsecurePassword:   "Insecure password. Please change it.",
-		ErrorIncorrectLength:    "Your username is an incorrect length. Length must be between 3 and 20.",
+		ErrorIncorrectLength:    "Your username is an incorrect length.",
+		ErrorInvalidUsername:    "Your username is not allowed. Please change it.",
 		ErrorAccountExists:      "Account already exists.",
 		ErrorIncorrectPassword:  "Incorrect password.",
 		ErrorNoRefreshToken:     "No refresh token found.",
@@ -79,6 +156,57 @@ var (
 		ErrorApplicationExists:  "Application already exists.",
 		ErrorInvalidJSON:        "Invalid JSON.",
 		ErrorUserNotFound:       "User not found.",
+
+		ErrorApplicationDisabled:    "This application has been disabled by its owner.",
+		ErrorApplicationMaintenance: "This application is under maintenance. Please try again later.",
+		ErrorApplicationFrozen:      "This application is unavailable while its owner's account is suspended.",
+		ErrorInvalidPolicy:          "Invalid policy. Device and IP limits can't be negative.",
+		ErrorIPLimit:                "This license has been used from too many IP addresses.",
+		ErrorRevokedLicense:         "License key has been revoked.",
+		ErrorInvalidWebhook:         "Invalid webhook. URLs must use http or https and events must be known.",
+		ErrorInvalidVariable:        "Variable not found.",
+		ErrorNotValidated:           "This session has not validated a license.",
+		ErrorInvalidArchive:         "Archive not found.",
+		ErrorArchiveExpired:         "The retention window of this archive has passed.",
+		ErrorArchiveParent:          "The parent of this archive has been deleted. Restore it first.",
+
+		ErrorForbidden:     "You don't have permission to do this.",
+		ErrorInvalidRole:   "Invalid role or permission.",
+		ErrorInvalidInvite: "Invitation is invalid or has expired.",
+		ErrorIsMember:      "This user is already a member of the owner.",
+		ErrorInvalidToken:  "API token is invalid, revoked or expired.",
+		ErrorInvalidScope:  "One or more scopes can't be granted to an API token.",
+
+		ErrorInvalidReseller: "Reseller not found or disabled.",
+		ErrorInvalidTemplate: "This license template isn't available.",
+		ErrorQuotaExceeded:   "The license quota for this application has been used up.",
+
+		ErrorInvalidPlan:      "Plan limits must not be negative.",
+		ErrorPlanApplications: "This owner has reached the maximum number of applications allowed by its plan.",
+		ErrorPlanLicenses:     "This owner has reached the maximum number of licenses allowed by its plan.",
+		ErrorPlanDevices:      "This owner has reached the maximum number of active devices allowed by its plan.",
+
+		ErrorAccountInUse:       "This account is the primary user of an owner that still has applications. Delete them first.",
+		ErrorInvalidCredentials: "Invalid username or password.",
+		ErrorTooManyAttempts:    "Too many failed login attempts. Please try again later.",
+		ErrorServerBusy:         "The server is busy. Please try again later.",
+		ErrorAccountSuspended:   "This account has been suspended.",
+		ErrorAccountExpired:     "This account has expired.",
+		ErrorSessionNotFound:    "Session not found or already revoked.",
+
+		ErrorInvalidCode:       "Invalid or already used two-factor code.",
+		ErrorInvalidTicket:     "Login ticket is invalid or has expired. Please log in again.",
+		ErrorTwoFactorRequired: "Two-factor authentication must be enabled for this account.",
+		ErrorTwoFactorEnabled:  "Two-factor authentication is already enabled.",
+
+		ErrorInvalidRule:  "Access rules need a fingerprint or a valid IP/CIDR, and an action of deny or allow.",
+		ErrorAccessDenied: "Access from this device or network has been blocked.",
+
+		ErrorInvalidEmail:      "Invalid email address.",
+		ErrorEmailExists:       "This email address is already used by another account.",
+		ErrorEmailVerified:     "This email address is already verified.",
+		ErrorNoEmail:           "This account has no email address.",
+		ErrorInvalidEmailToken: "Invalid or expired token. Please request a new one.",
 	}
 
 	errorType = map[error]int{
@@ -92,18 +220,27 @@ var (
 		ErrorEmptyFields:        http.StatusBadRequest,
 		ErrorOwnerNotFound:      http.StatusBadRequest,
 		ErrorInvalidLicense:     http.StatusBadRequest,
+		ErrorMalformedLicense:   http.StatusBadRequest,
+		ErrorInvalidMetadata:    http.StatusBadRequest,
+		ErrorInvalidFilter:      http.StatusBadRequest,
 		ErrorExpiredLicense:     http.StatusBadRequest,
 		ErrorInvalidFingerprint: http.StatusBadRequest,
 		ErrorNoSession:          http.StatusBadRequest,
 		ErrorCannotDecrypt:      http.StatusBadRequest,
 		ErrorNoIntegrity:        http.StatusBadRequest,
 		ErrorInvalidIntegrity:   http.StatusBadRequest,
+		ErrorInvalidBuild:       http.StatusBadRequest,
+		ErrorBlockedBuild:       http.StatusForbidden,
+		ErrorBuildExists:        http.StatusBadRequest,
+		ErrorInvalidHash:        http.StatusBadRequest,
+		ErrorInvalidStatus:      http.StatusBadRequest,
 		ErrorContextExpired:     http.StatusBadRequest,
 		ErrorInvalidOwner:       http.StatusBadRequest,
 		ErrorInvalidApp:         http.StatusBadRequest,
 		ErrorEmptyStruct:        http.StatusBadRequest,
 		ErrorInsecurePassword:   http.StatusBadRequest,
 		ErrorIncorrectLength:    http.StatusUnauthorized,
+		ErrorInvalidUsername:    http.StatusBadRequest,
 		ErrorAccountExists:      http.StatusBadRequest,
 		ErrorIncorrectPassword:  http.StatusBadRequest,
 		ErrorNoRefreshToken:     http.StatusBadRequest,
@@ -111,14 +248,91 @@ var (
 		ErrorApplicationExists:  http.StatusBadRequest,
 		ErrorInvalidJSON:        http.StatusBadRequest,
 		ErrorUserNotFound:       http.StatusBadRequest,
+
+		ErrorApplicationDisabled:    http.StatusForbidden,
+		ErrorApplicationMaintenance: http.StatusServiceUnavailable,
+		ErrorApplicationFrozen:      http.StatusForbidden,
+		ErrorInvalidPolicy:          http.StatusBadRequest,
+		ErrorIPLimit:                http.StatusBadRequest,
+		ErrorRevokedLicense:         http.StatusBadRequest,
+		ErrorInvalidWebhook:         http.StatusBadRequest,
+		ErrorInvalidVariable:        http.StatusBadRequest,
+		ErrorNotValidated:           http.StatusUnauthorized,
+		ErrorInvalidArchive:         http.StatusBadRequest,
+		ErrorArchiveExpired:         http.StatusGone,
+		ErrorArchiveParent:          http.StatusConflict,
+
+		ErrorForbidden:     http.StatusForbidden,
+		ErrorInvalidRole:   http.StatusBadRequest,
+		ErrorInvalidInvite: http.StatusBadRequest,
+		ErrorIsMember:      http.StatusBadRequest,
+		ErrorInvalidToken:  http.StatusUnauthorized,
+		ErrorInvalidScope:  http.StatusBadRequest,
+
+		ErrorInvalidReseller: http.StatusBadRequest,
+		ErrorInvalidTemplate: http.StatusBadRequest,
+		ErrorQuotaExceeded:   http.StatusForbidden,
+
+		ErrorInvalidPlan:      http.StatusBadRequest,
+		ErrorPlanApplications: http.StatusForbidden,
+		ErrorPlanLicenses:     http.StatusForbidden,
+		ErrorPlanDevices:      http.StatusForbidden,
+
+		ErrorAccountInUse:       http.StatusConflict,
+		ErrorInvalidCredentials: http.StatusUnauthorized,
+		ErrorTooManyAttempts:    http.StatusTooManyRequests,
+		ErrorServerBusy:         http.StatusServiceUnavailable,
+		ErrorAccountSuspended:   http.StatusForbidden,
+		ErrorAccountExpired:     http.StatusForbidden,
+		ErrorSessionNotFound:    http.StatusNotFound,
+
+		ErrorInvalidCode:       http.StatusUnauthorized,
+		ErrorInvalidTicket:     http.StatusUnauthorized,
+		ErrorTwoFactorRequired: http.StatusForbidden,
+		ErrorTwoFactorEnabled:  http.StatusBadRequest,
+
+		ErrorInvalidRule:  http.StatusBadRequest,
+		ErrorAccessDenied: http.StatusForbidden,
+
+		ErrorInvalidEmail:      http.StatusBadRequest,
+		ErrorEmailExists:       http.StatusConflict,
+		ErrorEmailVerified:     http.StatusConflict,
+		ErrorNoEmail:           http.StatusBadRequest,
+		ErrorInvalidEmailToken: http.StatusBadRequest,
 	}
 )
 
+// DetailedError attaches a caller facing message to one of the errors above.
+type DetailedError struct {
+	Err     error
+	Message string
+}
+
+func WithMessage(err error, message string) error {
+	return &DetailedError{Err: err, Message: message}
+}
+
+func (e *DetailedError) Error() string {
+	if e.Message == "" {
+		return e.Err.Error()
+	}
+
+	return e.Err.Error() + ": " + e.Message
+}
+
+func (e *DetailedError) Unwrap() error {
+	return e.Err
+}
+
 func ProperError(err error) string {
 	if v, ok := properErrors[err]; ok {
 		return v
 	}
 
+	if inner := errors.Unwrap(err); inner != nil {
+		return ProperError(inner)
+	}
+
 	return err.Error()
 }
 
@@ -127,5 +341,9 @@ func ErrorType(err error) int {
 		return v
 	}
 
+	if inner := errors.Unwrap(err); inner != nil {
+		return ErrorType(inner)
+	}
+
 	return http.StatusInternalServerError
 }
@@ -7,24 +7,100 @@ import (
 	"time"
 )
 
+type PasswordPolicy struct {
+	MinLength          int      `json:"min_length"`
+	MaxLength          int      `json:"max_length"`
+	RequireUpper       bool     `json:"require_upper"`
+	RequireLower       bool     `json:"require_lower"`
+	RequireDigit       bool     `json:"require_digit"`
+	RequireSpecial     bool     `json:"require_special"`
+	DisallowUsername   bool     `json:"disallow_username"`
+	DisallowedPatterns []string `json:"disallowed_patterns"`
+	// BreachedList is a directory of SHA-1 prefix files, empty turns the check off
+	BreachedList string `json:"breached_list"`
+}
+
+type UsernamePolicy struct {
+	MinLength          int      `json:"min_length"`
+	MaxLength          int      `json:"max_length"`
+	Pattern            string   `json:"pattern"`
+	DisallowedPatterns []string `json:"disallowed_patterns"`
+}
+
+// DefaultPasswordPolicy is what was enforced before the policy could be configured, it applies to anything the config leaves out.
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{MinLength: 8, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSpecial: true}
+}
+
+// DefaultUsernamePolicy is what was enforced before the policy could be configured, it applies to anything the config leaves out.
+func DefaultUsernamePolicy() UsernamePolicy {
+	return UsernamePolicy{MinLength: 3, MaxLength: 20}
+}
+
 type Config struct {
 	Verbose        bool  `json:"verbose"`
 	DestroySession int64 `json:"destroy_session"`
 	Security       struct {
-		AllowedContext uint64 `json:"allowed_context"`
-		Ratelimiter    bool   `json:"ratelimiter"`
-		Ratelimit      int    `json:"ratelimit"`
-		RatelimitExp   int    `json:"ratelimit_expiration"`
+		AllowedContext    uint64 `json:"allowed_context"`
+		Ratelimiter       bool   `json:"ratelimiter"`
+		Ratelimit         int    `json:"ratelimit"`
+		RatelimitExp      int    `json:"ratelimit_expiration"`
+		InviteExpiry      int64  `json:"invitation_expiry"`
+		EnforceTwoFactor  bool   `json:"enforce_two_factor"`
+		SuspendFreezeApps bool   `json:"suspend_freeze_apps"`
+		LoginTicketExpiry int64  `json:"login_ticket_expiry"`
+		LoginAttempts     int64  `json:"login_attempts"`
+		IPLoginAttempts   int64  `json:"ip_login_attempts"`
+		LoginWindow       int64  `json:"login_window"`
+		LoginDelay        int64  `json:"login_delay"`
+		LockoutDuration   int64  `json:"lockout_duration"`
+		VerifyEmailExpiry int64  `json:"verify_email_expiry"`
+		ResetExpiry       int64  `json:"reset_expiry"`
+		MaxSessions       int64  `json:"max_sessions"`
 	} `json:"security"`
 	Crypto struct {
 		AccessTokenExpiry  int64 `json:"access_token_expiry"`
 		RefreshTokenExpiry int64 `json:"refresh_token_expiry"`
 	} `json:"crypto"`
+	PasswordHashing struct {
+		Algorithm    string `json:"algorithm"`
+		Memory       uint32 `json:"memory"`
+		Iterations   uint32 `json:"iterations"`
+		Parallelism  uint8  `json:"parallelism"`
+		BcryptCost   int    `json:"bcrypt_cost"`
+		Workers      int    `json:"workers"`
+		QueueTimeout int64  `json:"queue_timeout"`
+	} `json:"password_hashing"`
+	PasswordPolicy PasswordPolicy `json:"password_policy"`
+	UsernamePolicy UsernamePolicy `json:"username_policy"`
+	Mail           struct {
+		Driver   string `json:"driver"`
+		From     string `json:"from"`
+		Host     string `json:"host"`
+		Port     int    `json:"port"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Path     string `json:"path"`
+	} `json:"mail"`
 	Mongo struct {
 		Host     string `json:"host"`
 		Database string `json:"database"`
 		Timeout  int    `json:"timeout"`
 	} `json:"mongo"`
+	Webhooks struct {
+		PollInterval     int64 `json:"poll_interval"`
+		MaxAttempts      int   `json:"max_attempts"`
+		Backoff          int64 `json:"backoff"`
+		Timeout          int64 `json:"timeout"`
+		FailureThreshold int64 `json:"failure_threshold"`
+		FailureWindow    int64 `json:"failure_window"`
+		// AllowPrivateURLs lets webhooks reach loopback and private addresses, only meant for local development
+		AllowPrivateURLs bool `json:"allow_private_urls"`
+	} `json:"webhooks"`
+	Archive struct {
+		Retention     int64 `json:"retention"`
+		PurgeInterval int64 `json:"purge_interval"`
+	} `json:"archive"`
 }
 
 var (
@@ -59,7 +135,8 @@ func LoadJsonUnsafe(file string, c any) {
 }
 
 func InitConfig() *Config {
-	var config *Config
+	// Sections missing from the file keep their defaults
+	config := &Config{PasswordPolicy: DefaultPasswordPolicy(), UsernamePolicy: DefaultUsernamePolicy()}
 	LoadJson(ConfigPath, &config)
 	go WaitForChanges(config, ConfigPath)
 
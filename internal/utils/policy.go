@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	types "github.com/Aran404/Goauth/internal/types"
+)
+
+// patterns caches compiled policy patterns, the config can be reloaded so they are keyed by the pattern itself.
+var patterns sync.Map
+
+func compile(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid policy pattern %q: %w", pattern, err)
+	}
+
+	patterns.Store(pattern, re)
+	return re, nil
+}
+
+// CompilePolicies compiles every pattern of the policies, so a bad one stops the server at startup instead of failing each request.
+func CompilePolicies(password *types.PasswordPolicy, username *types.UsernamePolicy) error {
+	all := append([]string{}, password.DisallowedPatterns...)
+	all = append(all, username.DisallowedPatterns...)
+	if username.Pattern != "" {
+		all = append(all, username.Pattern)
+	}
+
+	for _, pattern := range all {
+		if _, err := compile(pattern); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// CheckPassword checks a password against the policy, the error says which rule was broken.
+func CheckPassword(password, username string, policy *types.PasswordPolicy) error {
+	insecure := func(format string, a ...any) error {
+		return types.WithMessage(types.ErrorInsecurePassword, fmt.Sprintf(format, a...))
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < policy.MinLength {
+		return insecure("Password must be at least %d characters long.", policy.MinLength)
+	}
+
+	if policy.MaxLength > 0 && length > policy.MaxLength {
+		return insecure("Password must be at most %d characters long.", policy.MaxLength)
+	}
+
+	var upper, lower, digit, special bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case !unicode.IsLetter(r) && !unicode.IsSpace(r):
+			special = true
+		}
+	}
+
+	switch {
+	case policy.RequireUpper && !upper:
+		return insecure("Password must contain an uppercase letter.")
+	case policy.RequireLower && !lower:
+		return insecure("Password must contain a lowercase letter.")
+	case policy.RequireDigit && !digit:
+		return insecure("Password must contain a number.")
+	case policy.RequireSpecial && !special:
+		return insecure("Password must contain a special character.")
+	}
+
+	if policy.DisallowUsername && username != "" && strings.Contains(strings.ToLower(password), strings.ToLower(username)) {
+		return insecure("Password must not contain the username.")
+	}
+
+	for _, pattern := range policy.DisallowedPatterns {
+		re, err := compile(pattern)
+		if err != nil {
+			return err
+		}
+
+		if re.MatchString(password) {
+			return insecure("Password contains a disallowed word or pattern.")
+		}
+	}
+
+	if policy.BreachedList != "" {
+		breached, err := Breached(password, policy.BreachedList)
+		if err != nil {
+			return err
+		}
+
+		if breached {
+			return insecure("Password has appeared in a data breach, please choose another one.")
+		}
+	}
+
+	return nil
+}
+
+// CheckUsername checks the length and characters of a username against the policy.
+func CheckUsername(username string, policy *types.UsernamePolicy) error {
+	// An empty username is never allowed, whatever min_length says
+	minLength := max(policy.MinLength, 1)
+	length := utf8.RuneCountInString(username)
+	if policy.MaxLength <= 0 && length < minLength {
+		return types.WithMessage(types.ErrorIncorrectLength, fmt.Sprintf("Username must be at least %d characters long.", minLength))
+	}
+
+	if policy.MaxLength > 0 && (length < minLength || length > policy.MaxLength) {
+		return types.WithMessage(types.ErrorIncorrectLength, fmt.Sprintf("Username must be between %d and %d characters long.", minLength, policy.MaxLength))
+	}
+
+	if policy.Pattern != "" {
+		re, err := compile(policy.Pattern)
+		if err != nil {
+			return err
+		}
+
+		if !re.MatchString(username) {
+			return types.WithMessage(types.ErrorInvalidUsername, "Username contains characters that are not allowed.")
+		}
+	}
+
+	for _, pattern := range policy.DisallowedPatterns {
+		re, err := compile(pattern)
+		if err != nil {
+			return err
+		}
+
+		if re.MatchString(username) {
+			return types.WithMessage(types.ErrorInvalidUsername, "Username contains a disallowed word or pattern.")
+		}
+	}
+
+	return nil
+}
+
+// Breached looks a password up in a directory of breached SHA-1 hashes, split the same way as the Pwned Passwords range API.
+// Each file is named after the first 5 hex characters of the hash and holds one SUFFIX:COUNT line per hash.
+// Only the prefix decides which file is read, a missing file means no hash with that prefix is known.
+func Breached(password, dir string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	file, err := os.Open(filepath.Join(dir, prefix))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(line, suffix) {
+			return true, nil
+		}
+	}
+
+	return false, scanner.Err()
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"hash/crc32"
 	"math/rand"
 	"strings"
 	"time"
@@ -12,10 +13,15 @@ var (
 	NumbersList   = "0123456789"
 )
 
+// ChecksumLength is the size of the check segment appended to every license key.
+const ChecksumLength = 4
+
 type LicenseSettings struct {
 	Mask          string
 	OnlyCapitals  bool
 	OnlyLowercase bool
+	// Salt makes the checksum application specific, it should be the application ID.
+	Salt string
 }
 
 func CreateLicense(s ...LicenseSettings) string {
@@ -51,9 +57,42 @@ func CreateLicense(s ...LicenseSettings) string {
 		}
 	}
 
+	if len(s) > 0 && s[0].Salt != "" {
+		checksum := LicenseChecksum(b.String(), s[0].Salt)
+		if s[0].OnlyLowercase && !s[0].OnlyCapitals {
+			checksum = strings.ToLower(checksum)
+		}
+		b.WriteString("-" + checksum)
+	}
+
 	return b.String()
 }
 
+// LicenseChecksum computes the check segment of a license body.
+// This is not a security measure, it only lets clients catch typos without contacting the server.
+func LicenseChecksum(body, salt string) string {
+	charList := NumbersList + CapitalList
+	sum := crc32.ChecksumIEEE([]byte(salt + ":" + body))
+
+	b := make([]byte, ChecksumLength)
+	for i := range b {
+		b[i] = charList[sum%uint32(len(charList))]
+		sum /= uint32(len(charList))
+	}
+
+	return string(b)
+}
+
+// ValidLicenseChecksum checks that the last segment of a key matches the checksum of the rest of it.
+func ValidLicenseChecksum(key, salt string) bool {
+	i := strings.LastIndex(key, "-")
+	if i <= 0 || len(key)-i-1 != ChecksumLength {
+		return false
+	}
+
+	return strings.EqualFold(key[i+1:], LicenseChecksum(key[:i], salt))
+}
+
 func GenerateRandomString(length int) string {
 	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 	b := make([]byte, length)